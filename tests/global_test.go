@@ -1,20 +1,34 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"testing"
+	"time"
+	"unicode/utf8"
 
 	"github.com/gomarkdown/markdown"
 	"github.com/gomarkdown/markdown/ast"
 	"github.com/gomarkdown/markdown/parser"
 	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
 	"mvdan.cc/xurls/v2"
 )
 
@@ -25,13 +39,261 @@ type Validator interface {
 
 // MarkdownValidator orchestrates all validations
 type MarkdownValidator struct {
-	readmePath string
-	data       string
-	validators []Validator
+	readmePath           string
+	data                 string
+	validators           []Validator
+	terraformRoot        string
+	docsRoot             string
+	diffFile             string
+	diffBase             string
+	maxReadmeSize        int
+	localizedReadmes     []string
+	additionalFiles      []string
+	contentRules         map[string][]ContentRule
+	exampleDocs          bool
+	exampleProviders     bool
+	skipExamples         []string
+	allowedTerraformDirs []string
+	urlSeverity          Severity
+	urlChecks            int
+	strictResourceCounts bool
+	columnOrder          bool
+	urlSemaphore         chan struct{}
+	sectionNames         map[string]map[string]string
+	urlCache             *urlCache
+	variableUsage        bool
+	dynamicReferences    bool
+	outputDescriptions   bool
+	httpClient           *http.Client
+	tlsConfig            *tls.Config
+	proxyFromEnvironment bool
+}
+
+// Option configures a MarkdownValidator
+type Option func(*MarkdownValidator)
+
+// Severity tells whether a validation error fails the run or is only reported
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+const (
+	// CheckURLLiveness is the check requesting every URL in a README
+	CheckURLLiveness = "url-liveness"
+	// CheckChangedLines is the check restricting URL liveness to changed README lines
+	CheckChangedLines = "changed-lines"
+	// CheckVariableUsage is the check comparing declared variables with var references
+	CheckVariableUsage = "variable-usage"
+	// CheckOutputDescriptions is the check requiring every output to have a description
+	CheckOutputDescriptions = "output-descriptions"
+	// CheckStrayTerraform is the check flagging Terraform files outside the module layout
+	CheckStrayTerraform = "stray-terraform"
+)
+
+// ValidationError is a validation error tagged with the check that produced it and its severity
+type ValidationError struct {
+	Check    string
+	Severity Severity
+	Err      error
+}
+
+// Error returns the message of the underlying error
+func (e *ValidationError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// defaultHTTPClient is shared by all components making outbound calls unless configured otherwise
+var defaultHTTPClient = &http.Client{Timeout: 30 * time.Second}
+
+// WithTerraformRoot sets the directory holding the Terraform configuration, defaults to the caller checkout
+func WithTerraformRoot(dir string) Option {
+	return func(mv *MarkdownValidator) {
+		mv.terraformRoot = dir
+	}
+}
+
+// WithDocsRoot sets the directory holding the required documentation files, defaults to the README directory
+func WithDocsRoot(dir string) Option {
+	return func(mv *MarkdownValidator) {
+		mv.docsRoot = dir
+	}
+}
+
+// WithChangedLinesFromDiff restricts URL liveness checks to README lines added or modified in the given unified diff file
+func WithChangedLinesFromDiff(path string) Option {
+	return func(mv *MarkdownValidator) {
+		mv.diffFile = path
+	}
+}
+
+// WithChangedLinesFromGit restricts URL liveness checks to README lines committed since the merge base with the given git base.
+// When the diff fails, e.g. because the base was not fetched, all URLs are checked and a warning is reported.
+func WithChangedLinesFromGit(base string) Option {
+	return func(mv *MarkdownValidator) {
+		mv.diffBase = base
+	}
+}
+
+// WithMaxReadmeSize sets the README size in bytes above which URLs are extracted line by line
+func WithMaxReadmeSize(size int) Option {
+	return func(mv *MarkdownValidator) {
+		mv.maxReadmeSize = size
+	}
+}
+
+// WithURLLivenessSeverity sets the severity of unreachable URLs. Defaults to warning on scheduled
+// workflow runs, so outages of third-party sites don't fail them, and to error otherwise.
+func WithURLLivenessSeverity(severity Severity) Option {
+	return func(mv *MarkdownValidator) {
+		mv.urlSeverity = severity
+	}
+}
+
+// WithConcurrentURLChecks limits the number of URLs requested at the same time, defaults to 10
+func WithConcurrentURLChecks(n int) Option {
+	return func(mv *MarkdownValidator) {
+		mv.urlChecks = n
+	}
+}
+
+// WithStrictResourceCounts requires the README to list a resource type as often as the code
+// defines it, instead of at least once
+func WithStrictResourceCounts() Option {
+	return func(mv *MarkdownValidator) {
+		mv.strictResourceCounts = true
+	}
+}
+
+// WithColumnOrder requires the required columns of README tables to keep their declared relative
+// order, instead of only being present
+func WithColumnOrder() Option {
+	return func(mv *MarkdownValidator) {
+		mv.columnOrder = true
+	}
+}
+
+// WithAdditionalFiles requires extra files, relative to the docs root, to exist and not be empty
+func WithAdditionalFiles(names ...string) Option {
+	return func(mv *MarkdownValidator) {
+		mv.additionalFiles = append(mv.additionalFiles, names...)
+	}
+}
+
+// WithFileContentRules sets content rules per file, keyed by the path relative to the docs or
+// terraform root. Every file must be one of the required or additional files.
+func WithFileContentRules(rules map[string][]ContentRule) Option {
+	return func(mv *MarkdownValidator) {
+		mv.contentRules = rules
+	}
+}
+
+// WithExampleDocs enables checking that every example under the Terraform root's examples
+// directory has a README with a title and a fenced HCL block matching its module call
+func WithExampleDocs() Option {
+	return func(mv *MarkdownValidator) {
+		mv.exampleDocs = true
+	}
+}
+
+// WithExampleProviders enables checking the provider blocks of every example: azurerm providers
+// need a features block, aliases passed to modules and resources must be declared, and declared
+// providers must be used
+func WithExampleProviders() Option {
+	return func(mv *MarkdownValidator) {
+		mv.exampleProviders = true
+	}
+}
+
+// WithSkipExamples excludes examples whose directory name matches any of the glob patterns
+func WithSkipExamples(patterns ...string) Option {
+	return func(mv *MarkdownValidator) {
+		mv.skipExamples = append(mv.skipExamples, patterns...)
+	}
+}
+
+// WithAllowedTerraformDirs marks directories, relative to the docs root, that may hold Terraform
+// files besides the Terraform root, modules and examples, e.g. test fixtures
+func WithAllowedTerraformDirs(dirs ...string) Option {
+	return func(mv *MarkdownValidator) {
+		mv.allowedTerraformDirs = append(mv.allowedTerraformDirs, dirs...)
+	}
+}
+
+// WithLocalizedReadmes adds translated READMEs, e.g. README.nl.md, that get the same structural checks
+// as the main README. The locale is taken from the file name and prefixes every error.
+func WithLocalizedReadmes(paths ...string) Option {
+	return func(mv *MarkdownValidator) {
+		mv.localizedReadmes = append(mv.localizedReadmes, paths...)
+	}
+}
+
+// WithSectionNames maps section names to their translation for a locale, e.g. "Inputs" to "Invoerparameters"
+// for "nl". Sections without a mapping keep their English name.
+func WithSectionNames(locale string, names map[string]string) Option {
+	return func(mv *MarkdownValidator) {
+		if mv.sectionNames == nil {
+			mv.sectionNames = make(map[string]map[string]string)
+		}
+		mv.sectionNames[locale] = names
+	}
+}
+
+// WithVariableUsage enables checking that the variables of the root module and its submodules are
+// all referenced, and that every var reference is declared. Unused variables are only warnings.
+func WithVariableUsage() Option {
+	return func(mv *MarkdownValidator) {
+		mv.variableUsage = true
+	}
+}
+
+// WithDynamicReferences enables checking that dynamic block for_each expressions in the root module
+// and its submodules only refer to declared variables, locals, modules, data sources and resources
+func WithDynamicReferences() Option {
+	return func(mv *MarkdownValidator) {
+		mv.dynamicReferences = true
+	}
+}
+
+// WithOutputDescriptions enables checking that every output of the root module and its submodules
+// has a non-empty description. Outputs without one are only warnings.
+func WithOutputDescriptions() Option {
+	return func(mv *MarkdownValidator) {
+		mv.outputDescriptions = true
+	}
+}
+
+// WithHTTPClient sets the client used for all outbound HTTP calls
+func WithHTTPClient(client *http.Client) Option {
+	return func(mv *MarkdownValidator) {
+		mv.httpClient = client
+	}
+}
+
+// WithTLSConfig sets the TLS configuration used for outbound HTTP calls, e.g. to trust a private CA
+func WithTLSConfig(cfg *tls.Config) Option {
+	return func(mv *MarkdownValidator) {
+		mv.tlsConfig = cfg
+	}
+}
+
+// WithProxyFromEnvironment makes an injected *http.Transport honour HTTP_PROXY, HTTPS_PROXY and NO_PROXY.
+// The default client already does, so the option only matters together with WithHTTPClient.
+func WithProxyFromEnvironment() Option {
+	return func(mv *MarkdownValidator) {
+		mv.proxyFromEnvironment = true
+	}
 }
 
 // NewMarkdownValidator creates a new MarkdownValidator
-func NewMarkdownValidator(readmePath string) (*MarkdownValidator, error) {
+func NewMarkdownValidator(readmePath string, opts ...Option) (*MarkdownValidator, error) {
 	if envPath := os.Getenv("README_PATH"); envPath != "" {
 		readmePath = envPath
 	}
@@ -48,23 +310,215 @@ func NewMarkdownValidator(readmePath string) (*MarkdownValidator, error) {
 	data := string(dataBytes)
 
 	mv := &MarkdownValidator{
-		readmePath: absReadmePath,
-		data:       data,
+		readmePath:    absReadmePath,
+		data:          data,
+		maxReadmeSize: defaultMaxReadmeSize,
+		urlCache:      newURLCache(),
+		urlChecks:     defaultConcurrentURLChecks,
+	}
+
+	for _, opt := range opts {
+		opt(mv)
+	}
+
+	if mv.urlSeverity == "" {
+		mv.urlSeverity = SeverityError
+		if os.Getenv("GITHUB_EVENT_NAME") == "schedule" {
+			mv.urlSeverity = SeverityWarning
+		}
+	}
+	if mv.urlSeverity != SeverityError && mv.urlSeverity != SeverityWarning {
+		return nil, fmt.Errorf("unknown URL liveness severity: %s", mv.urlSeverity)
+	}
+
+	if mv.urlChecks < 1 {
+		return nil, fmt.Errorf("concurrent URL checks must be at least 1, got %d", mv.urlChecks)
+	}
+	mv.urlSemaphore = make(chan struct{}, mv.urlChecks)
+
+	if mv.terraformRoot == "" {
+		if mv.terraformRoot, err = defaultTerraformRoot(); err != nil {
+			return nil, err
+		}
+	}
+	if mv.docsRoot == "" {
+		mv.docsRoot = filepath.Dir(absReadmePath)
+	}
+
+	if mv.terraformRoot, err = filepath.Abs(mv.terraformRoot); err != nil {
+		return nil, fmt.Errorf("failed to get absolute terraform root: %v", err)
+	}
+	if mv.docsRoot, err = filepath.Abs(mv.docsRoot); err != nil {
+		return nil, fmt.Errorf("failed to get absolute docs root: %v", err)
+	}
+
+	client, err := mv.client()
+	if err != nil {
+		return nil, err
 	}
 
 	// Initialize validators
-	mv.validators = []Validator{
-		NewSectionValidator(data),
-		NewFileValidator(absReadmePath),
-		NewURLValidator(data),
-		NewTerraformDefinitionValidator(data),
-		NewItemValidator(data, "Variables", "variable", "Inputs", "variables.tf"),
-		NewItemValidator(data, "Outputs", "output", "Outputs", "outputs.tf"),
+	readmeValidators, err := mv.readmeValidators(absReadmePath, data, nil, client)
+	if err != nil {
+		return nil, err
+	}
+	fileValidator := NewFileValidator(absReadmePath, mv.docsRoot, mv.terraformRoot, mv.additionalFiles...)
+
+	names := make([]string, 0, len(mv.contentRules))
+	for name := range mv.contentRules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := fileValidator.addContentRules(name, mv.contentRules[name], mv.docsRoot, mv.terraformRoot); err != nil {
+			return nil, err
+		}
+	}
+
+	mv.validators = append([]Validator{fileValidator}, readmeValidators...)
+	mv.validators = append(mv.validators, NewStrayTerraformValidator(mv.terraformLayout()))
+
+	for _, pattern := range mv.skipExamples {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid example skip pattern %q: %v", pattern, err)
+		}
+	}
+	examplesDir := filepath.Join(mv.terraformRoot, "examples")
+	if mv.exampleDocs {
+		mv.validators = append(mv.validators, NewExampleDocsValidator(examplesDir, mv.skipExamples))
+	}
+	if mv.exampleProviders {
+		mv.validators = append(mv.validators, NewExampleProvidersValidator(examplesDir, mv.skipExamples))
+	}
+	if mv.variableUsage {
+		mv.validators = append(mv.validators, NewVariableUsageValidator(mv.terraformRoot))
+	}
+	if mv.dynamicReferences {
+		mv.validators = append(mv.validators, NewDynamicReferenceValidator(mv.terraformRoot))
+	}
+	if mv.outputDescriptions {
+		mv.validators = append(mv.validators, NewOutputDescriptionValidator(mv.terraformRoot))
+	}
+
+	for _, path := range mv.localizedReadmes {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(mv.docsRoot, path)
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read localized README: %v", err)
+		}
+
+		locale := readmeLocale(path)
+		localized, err := mv.readmeValidators(path, string(content), mv.sectionNames[locale], client)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range localized {
+			mv.validators = append(mv.validators, localizedValidator{locale: locale, Validator: v})
+		}
 	}
 
 	return mv, nil
 }
 
+// readmeValidators creates the validators checking a single README against the Terraform code,
+// matching sections by their translated names when given
+func (mv *MarkdownValidator) readmeValidators(path, data string, names map[string]string, client *http.Client) ([]Validator, error) {
+	urlValidator := NewURLValidator(data, client)
+	urlValidator.cache = mv.urlCache
+	urlValidator.lineScan = len(data) > mv.maxReadmeSize
+	urlValidator.severity = mv.urlSeverity
+	urlValidator.semaphore = mv.urlSemaphore
+	if mv.diffFile != "" || mv.diffBase != "" {
+		// Without a usable diff, e.g. an unfetched base ref, all URLs are checked instead
+		if changed, err := mv.changedLines(path); err != nil {
+			urlValidator.diffErr = err
+		} else {
+			urlValidator.RestrictToLines(changed)
+		}
+	}
+
+	// The document is parsed once and the AST shared by all validators
+	rootNode := parseMarkdown(data)
+
+	definitionValidator := NewTerraformDefinitionValidator(rootNode, sectionName(names, "Resources"), mv.terraformRoot)
+	definitionValidator.strict = mv.strictResourceCounts
+
+	sectionValidator := NewSectionValidator(rootNode, names)
+	sectionValidator.columnOrder = mv.columnOrder
+
+	return []Validator{
+		NewDuplicateSectionValidator(data),
+		sectionValidator,
+		urlValidator,
+		definitionValidator,
+		NewItemValidator(rootNode, "Variables", "variable", sectionName(names, "Inputs"), "variables.tf", mv.terraformRoot),
+		NewItemValidator(rootNode, "Outputs", "output", sectionName(names, "Outputs"), "outputs.tf", mv.terraformRoot),
+	}, nil
+}
+
+// localizedValidator prefixes the errors of a validator with the locale of the README it checks
+type localizedValidator struct {
+	locale string
+	Validator
+}
+
+// Validate runs the wrapped validator and prefixes its errors with the locale
+func (lv localizedValidator) Validate() []error {
+	errs := lv.Validator.Validate()
+	for i, err := range errs {
+		errs[i] = fmt.Errorf("[%s] %w", lv.locale, err)
+	}
+	return errs
+}
+
+// readmeLocale derives the locale from a localized README name, e.g. "nl" for README.nl.md
+func readmeLocale(path string) string {
+	name := filepath.Base(path)
+	if ext := filepath.Ext(name); strings.EqualFold(ext, ".md") {
+		name = strings.TrimSuffix(name, ext)
+	}
+	if i := strings.IndexByte(name, '.'); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
+
+// sectionName returns the translated name of a section, or the section itself when not mapped
+func sectionName(names map[string]string, section string) string {
+	if name, ok := names[section]; ok && name != "" {
+		return name
+	}
+	return section
+}
+
+// discoverLocalizedReadmes returns the translated READMEs next to a README, e.g. README.nl.md
+func discoverLocalizedReadmes(readmePath string) ([]string, error) {
+	name := strings.TrimSuffix(filepath.Base(readmePath), filepath.Ext(readmePath))
+	paths, err := filepath.Glob(filepath.Join(filepath.Dir(readmePath), name+".*.md"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to search localized READMEs: %v", err)
+	}
+	return paths, nil
+}
+
+// loadSectionNames reads the translated section names per locale from a JSON file, e.g.
+// {"nl": {"Inputs": "Invoerparameters"}}
+func loadSectionNames(path string) (map[string]map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read section names: %v", err)
+	}
+
+	var names map[string]map[string]string
+	if err := json.Unmarshal(data, &names); err != nil {
+		return nil, fmt.Errorf("failed to parse section names in %s: %v", filepath.Base(path), err)
+	}
+	return names, nil
+}
+
 // Validate runs all registered validators
 func (mv *MarkdownValidator) Validate() []error {
 	var allErrors []error
@@ -74,21 +528,179 @@ func (mv *MarkdownValidator) Validate() []error {
 	return allErrors
 }
 
+// terraformLayout resolves where Terraform files are expected from the configured roots
+func (mv *MarkdownValidator) terraformLayout() terraformLayout {
+	layout := terraformLayout{scanRoot: mv.terraformRoot, root: mv.terraformRoot}
+
+	// With a split layout the Terraform root usually sits inside the repository holding the docs
+	if rel, err := filepath.Rel(mv.docsRoot, mv.terraformRoot); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		layout.scanRoot = mv.docsRoot
+	}
+
+	for _, base := range []string{mv.terraformRoot, mv.docsRoot} {
+		layout.allowed = append(layout.allowed, filepath.Join(base, "modules"), filepath.Join(base, "examples"))
+	}
+	for _, dir := range mv.allowedTerraformDirs {
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(mv.docsRoot, dir)
+		}
+		layout.allowed = append(layout.allowed, filepath.Clean(dir))
+	}
+	return layout
+}
+
+// URLCounts returns the number of unique URLs checked for liveness, skipped and rejected as malformed
+// by the last validation run. URLs shared between READMEs are counted once, and a URL checked for one
+// README is not counted as skipped for another.
+func (mv *MarkdownValidator) URLCounts() (checked, skipped, invalid int) {
+	checkedURLs := make(map[string]bool)
+	skippedURLs := make(map[string]bool)
+	invalidURLs := make(map[string]bool)
+	for _, v := range mv.validators {
+		if lv, ok := v.(localizedValidator); ok {
+			v = lv.Validator
+		}
+		if uv, ok := v.(*URLValidator); ok {
+			for u := range uv.checked {
+				checkedURLs[u] = true
+			}
+			for u := range uv.skipped {
+				skippedURLs[u] = true
+			}
+			for u := range uv.invalid {
+				invalidURLs[u] = true
+			}
+		}
+	}
+
+	for u := range skippedURLs {
+		if !checkedURLs[u] {
+			skipped++
+		}
+	}
+	return len(checkedURLs), skipped, len(invalidURLs)
+}
+
+// ValidateWithSeverity runs all registered validators and separates failing errors from warnings.
+// Errors without a severity are treated as failing.
+func (mv *MarkdownValidator) ValidateWithSeverity() (errs []error, warnings []error) {
+	for _, err := range mv.Validate() {
+		var verr *ValidationError
+		if errors.As(err, &verr) && verr.Severity == SeverityWarning {
+			warnings = append(warnings, err)
+			continue
+		}
+		errs = append(errs, err)
+	}
+	return errs, warnings
+}
+
+// defaultTerraformRoot returns the caller checkout inside the workflow workspace
+func defaultTerraformRoot() (string, error) {
+	workspace := os.Getenv("GITHUB_WORKSPACE")
+	if workspace == "" {
+		var err error
+		workspace, err = os.Getwd()
+		if err != nil {
+			return "", fmt.Errorf("failed to get current working directory: %v", err)
+		}
+	}
+	return filepath.Join(workspace, "caller"), nil
+}
+
+// changedLines returns the line ranges of a README touched by the configured diff
+func (mv *MarkdownValidator) changedLines(path string) ([]LineRange, error) {
+	if mv.diffFile != "" {
+		f, err := os.Open(mv.diffFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open diff file: %v", err)
+		}
+		defer f.Close()
+		return parseUnifiedDiff(f, path)
+	}
+
+	// Comparing against the merge base leaves out changes made on the base branch since the branch point
+	cmd := exec.Command("git", "diff", "-U0", "--no-color", mv.diffBase+"...HEAD", "--", filepath.Base(path))
+	cmd.Dir = filepath.Dir(path)
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff %s against %s: %v", filepath.Base(path), mv.diffBase, err)
+	}
+	return parseUnifiedDiff(bytes.NewReader(out), path)
+}
+
+// client returns the HTTP client shared by all components making outbound calls. The TLS and proxy
+// conveniences can only be applied to an *http.Transport, combining them with a custom round tripper
+// is an error rather than silently dropping them.
+func (mv *MarkdownValidator) client() (*http.Client, error) {
+	client := mv.httpClient
+	if client == nil {
+		client = defaultHTTPClient
+	}
+
+	if mv.tlsConfig == nil && !mv.proxyFromEnvironment {
+		return client, nil
+	}
+
+	var transport *http.Transport
+	switch rt := client.Transport.(type) {
+	case nil:
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	case *http.Transport:
+		transport = rt.Clone()
+	default:
+		return nil, fmt.Errorf("TLS config and proxy options need an *http.Transport, the HTTP client uses %T", rt)
+	}
+
+	if mv.tlsConfig != nil {
+		transport.TLSClientConfig = mv.tlsConfig
+	}
+	if mv.proxyFromEnvironment {
+		transport.Proxy = http.ProxyFromEnvironment
+	}
+
+	configured := *client
+	configured.Transport = transport
+	return &configured, nil
+}
+
+// loadCACertificates returns a TLS config trusting the system roots and the PEM certificates in the
+// file, e.g. the CA of a TLS inspecting proxy
+func loadCACertificates(path string) (*tls.Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA certificates: %v", err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no PEM certificates found in %s", filepath.Base(path))
+	}
+	return &tls.Config{RootCAs: pool}, nil
+}
+
+// sectionLevel is the heading level of the README sections
+const sectionLevel = 2
+
 type Section struct {
 	Header       string
 	RequiredCols []string
 	OptionalCols []string
+	columnOrder  bool
 }
 
 // SectionValidator validates markdown sections
 type SectionValidator struct {
-	data     string
-	sections []Section
-	rootNode ast.Node
+	sections    []Section
+	rootNode    ast.Node
+	columnOrder bool
 }
 
-// NewSectionValidator creates a new SectionValidator
-func NewSectionValidator(data string) *SectionValidator {
+// NewSectionValidator creates a new SectionValidator, matching headers by their translated names when given
+func NewSectionValidator(rootNode ast.Node, names map[string]string) *SectionValidator {
 	sections := []Section{
 		{Header: "Goals"},
 		{Header: "Non-Goals"},
@@ -109,12 +721,11 @@ func NewSectionValidator(data string) *SectionValidator {
 		{Header: "Reference"},
 	}
 
-	extensions := parser.CommonExtensions | parser.AutoHeadingIDs
-	p := parser.NewWithExtensions(extensions)
-	rootNode := markdown.Parse([]byte(data), p)
+	for i := range sections {
+		sections[i].Header = sectionName(names, sections[i].Header)
+	}
 
 	return &SectionValidator{
-		data:     data,
 		sections: sections,
 		rootNode: rootNode,
 	}
@@ -124,6 +735,7 @@ func NewSectionValidator(data string) *SectionValidator {
 func (sv *SectionValidator) Validate() []error {
 	var allErrors []error
 	for _, section := range sv.sections {
+		section.columnOrder = sv.columnOrder
 		allErrors = append(allErrors, section.validate(sv.rootNode)...)
 	}
 	return allErrors
@@ -135,9 +747,13 @@ func (s Section) validate(rootNode ast.Node) []error {
 	found := false
 
 	ast.WalkFunc(rootNode, func(node ast.Node, entering bool) ast.WalkStatus {
-		if heading, ok := node.(*ast.Heading); ok && entering && heading.Level == 2 {
+		if heading, ok := node.(*ast.Heading); ok && entering && heading.Level == sectionLevel {
 			text := strings.TrimSpace(extractText(heading))
 			if strings.EqualFold(text, s.Header) || strings.EqualFold(text, s.Header+"s") {
+				// Duplicates are reported separately, only the first occurrence is validated
+				if found {
+					return ast.SkipChildren
+				}
 				found = true
 
 				if len(s.RequiredCols) > 0 || len(s.OptionalCols) > 0 {
@@ -147,305 +763,1967 @@ func (s Section) validate(rootNode ast.Node) []error {
 						if err != nil {
 							errors = append(errors, err)
 						} else {
-							errors = append(errors, validateColumns(s.Header, s.RequiredCols, s.OptionalCols, actualHeaders)...)
+							errors = append(errors, validateColumns(s.Header, s.RequiredCols, s.OptionalCols, actualHeaders, s.columnOrder)...)
 						}
 					} else {
 						errors = append(errors, formatError("missing table after header: %s", s.Header))
 					}
 				}
-				return ast.SkipChildren
+				return ast.SkipChildren
+			}
+		}
+		return ast.GoToNext
+	})
+
+	if !found {
+		errors = append(errors, compareHeaders(s.Header, ""))
+	}
+
+	return errors
+}
+
+// DuplicateSectionValidator reports section headings that appear more than once
+type DuplicateSectionValidator struct {
+	data string
+}
+
+// NewDuplicateSectionValidator creates a new DuplicateSectionValidator
+func NewDuplicateSectionValidator(data string) *DuplicateSectionValidator {
+	return &DuplicateSectionValidator{data: data}
+}
+
+// Validate checks all section headings, including ones that are not required, for duplicates.
+// Singular and plural forms such as "Input" and "Inputs" count as the same section.
+func (dv *DuplicateSectionValidator) Validate() []error {
+	// Headings inside code and HTML comments are not rendered and can't clash
+	headings, _ := scanMarkdownLines(maskMarkdownCode(dv.data))
+
+	var order []string
+	occurrences := make(map[string][]markdownLine)
+	for _, h := range headings {
+		if h.level != sectionLevel || h.text == "" {
+			continue
+		}
+		key := strings.TrimSuffix(strings.ToLower(h.text), "s")
+		if _, ok := occurrences[key]; !ok {
+			order = append(order, key)
+		}
+		occurrences[key] = append(occurrences[key], h)
+	}
+
+	var errors []error
+	for _, key := range order {
+		found := occurrences[key]
+		if len(found) < 2 {
+			continue
+		}
+
+		lines := make([]string, 0, len(found))
+		for _, h := range found {
+			lines = append(lines, fmt.Sprintf("'%s' on line %d", h.text, h.line))
+		}
+		errors = append(errors, formatError("duplicate section heading, only the first occurrence is validated:\n  %s",
+			strings.Join(lines, "\n  ")))
+	}
+
+	return errors
+}
+
+// ColumnIssue classifies how a table header row differs from the expected columns
+type ColumnIssue string
+
+const (
+	ColumnCaseMismatch ColumnIssue = "case mismatch"
+	ColumnMissing      ColumnIssue = "missing column"
+	ColumnExtra        ColumnIssue = "extra column"
+	ColumnDuplicate    ColumnIssue = "duplicate column"
+	ColumnSwapped      ColumnIssue = "swapped order"
+	ColumnMixed        ColumnIssue = "mixed"
+)
+
+// columnDiff is a single classified difference between expected and actual columns
+type columnDiff struct {
+	issue  ColumnIssue
+	column string
+	want   string
+}
+
+func (d columnDiff) String() string {
+	switch d.issue {
+	case ColumnCaseMismatch:
+		return fmt.Sprintf("%s: '%s' should be '%s'", d.issue, d.column, d.want)
+	case ColumnSwapped:
+		return fmt.Sprintf("%s: '%s' found where '%s' was expected", d.issue, d.column, d.want)
+	default:
+		return fmt.Sprintf("%s: '%s'", d.issue, d.column)
+	}
+}
+
+// ColumnError reports a table header row that does not match the expected columns
+type ColumnError struct {
+	Header   string
+	Issue    ColumnIssue
+	Expected []string
+	Actual   []string
+	diffs    []columnDiff
+}
+
+func (e *ColumnError) Error() string {
+	causes := make([]string, 0, len(e.diffs))
+	for _, d := range e.diffs {
+		causes = append(causes, d.String())
+	}
+	expected, actual, caret := renderColumnRows(e.Expected, e.Actual)
+	return fmt.Sprintf("column mismatch in table under header: %s\n  %s\n  expected: %s\n  actual:   %s\n            %s",
+		e.Header, strings.Join(causes, "\n  "), expected, actual, caret)
+}
+
+// validateColumns compares the actual table columns against the required and optional ones,
+// checking the order of the required columns only when ordered is set
+func validateColumns(header string, required, optional, actual []string, ordered bool) []error {
+	diffs, expected := classifyColumns(required, optional, actual, ordered)
+	if len(diffs) == 0 {
+		return nil
+	}
+
+	issue := diffs[0].issue
+	for _, d := range diffs[1:] {
+		if d.issue != issue {
+			issue = ColumnMixed
+			break
+		}
+	}
+
+	return []error{&ColumnError{
+		Header:   header,
+		Issue:    issue,
+		Expected: expected,
+		Actual:   actual,
+		diffs:    diffs,
+	}}
+}
+
+// classifyColumns classifies every difference between the actual and the expected columns
+// and returns them together with the header row the table should have had. Optional columns
+// may appear anywhere, required columns must keep their declared relative order when ordered is set.
+func classifyColumns(required, optional, actual []string, ordered bool) ([]columnDiff, []string) {
+	// Map lowercased names to their canonical spelling
+	validColumns := make(map[string]string)
+	for _, col := range required {
+		validColumns[strings.ToLower(col)] = col
+	}
+	for _, col := range optional {
+		validColumns[strings.ToLower(col)] = col
+	}
+
+	var diffs []columnDiff
+	var expected []string
+	foundColumns := make(map[string]bool)
+
+	for _, act := range actual {
+		canonical, ok := validColumns[strings.ToLower(act)]
+		if !ok {
+			diffs = append(diffs, columnDiff{issue: ColumnExtra, column: act})
+			continue
+		}
+		if foundColumns[canonical] {
+			diffs = append(diffs, columnDiff{issue: ColumnDuplicate, column: act})
+			continue
+		}
+		if canonical != act {
+			diffs = append(diffs, columnDiff{issue: ColumnCaseMismatch, column: act, want: canonical})
+		}
+		foundColumns[canonical] = true
+		expected = append(expected, canonical)
+	}
+
+	// Check the relative order of the required columns that are present
+	if ordered {
+		requiredIndex := make(map[string]int, len(required))
+		for i, col := range required {
+			requiredIndex[col] = i
+		}
+
+		var positions []int
+		var present []string
+		for i, col := range expected {
+			if _, ok := requiredIndex[col]; ok {
+				positions = append(positions, i)
+				present = append(present, col)
+			}
+		}
+
+		sorted := append([]string(nil), present...)
+		sort.SliceStable(sorted, func(i, j int) bool {
+			return requiredIndex[sorted[i]] < requiredIndex[sorted[j]]
+		})
+
+		for i := range present {
+			if present[i] != sorted[i] {
+				diffs = append(diffs, columnDiff{issue: ColumnSwapped, column: present[i], want: sorted[i]})
+				break
+			}
+		}
+		for i, pos := range positions {
+			expected[pos] = sorted[i]
+		}
+	}
+
+	for _, req := range required {
+		if !foundColumns[req] {
+			diffs = append(diffs, columnDiff{issue: ColumnMissing, column: req})
+			expected = append(expected, req)
+		}
+	}
+
+	return diffs, expected
+}
+
+// renderColumnRows renders the expected and actual header rows as aligned table lines,
+// together with a caret line marking the first column that differs
+func renderColumnRows(expected, actual []string) (string, string, string) {
+	n := len(expected)
+	if len(actual) > n {
+		n = len(actual)
+	}
+
+	cell := func(cols []string, i int) string {
+		if i < len(cols) {
+			return cols[i]
+		}
+		return ""
+	}
+
+	var exp, act strings.Builder
+	exp.WriteString("|")
+	act.WriteString("|")
+
+	caret := ""
+	offset := 1
+	for i := 0; i < n; i++ {
+		e, a := cell(expected, i), cell(actual, i)
+		width := utf8.RuneCountInString(e)
+		if w := utf8.RuneCountInString(a); w > width {
+			width = w
+		}
+
+		if caret == "" && e != a {
+			caret = strings.Repeat(" ", offset+1) + "^"
+		}
+
+		fmt.Fprintf(&exp, " %-*s |", width, e)
+		fmt.Fprintf(&act, " %-*s |", width, a)
+		offset += width + 3
+	}
+
+	return exp.String(), act.String(), caret
+}
+
+// getNextSibling returns the next sibling of a node
+func getNextSibling(node ast.Node) ast.Node {
+	parent := node.GetParent()
+	if parent == nil {
+		return nil
+	}
+	children := parent.GetChildren()
+	for i, n := range children {
+		if n == node && i+1 < len(children) {
+			return children[i+1]
+		}
+	}
+	return nil
+}
+
+// extractTableHeaders extracts headers from a markdown table
+func extractTableHeaders(table *ast.Table) ([]string, error) {
+	headers := []string{}
+
+	if len(table.GetChildren()) == 0 {
+		return nil, fmt.Errorf("table is empty")
+	}
+
+	// The first child should be TableHeader
+	var headerNode *ast.TableHeader
+	for _, child := range table.GetChildren() {
+		if h, ok := child.(*ast.TableHeader); ok {
+			headerNode = h
+			break
+		}
+	}
+
+	if headerNode == nil {
+		return nil, fmt.Errorf("table has no header row")
+	}
+
+	// The header row is under TableHeader
+	for _, rowNode := range headerNode.GetChildren() {
+		if row, ok := rowNode.(*ast.TableRow); ok {
+			for _, cellNode := range row.GetChildren() {
+				if cell, ok := cellNode.(*ast.TableCell); ok {
+					headerText := strings.TrimSpace(extractTextFromNodes(cell.GetChildren()))
+					headers = append(headers, headerText)
+				}
+			}
+		}
+	}
+
+	return headers, nil
+}
+
+// FileValidator validates the presence of required files
+type FileValidator struct {
+	files []string
+	rules map[string][]ContentRule
+}
+
+// NewFileValidator creates a new FileValidator, resolving documentation and additional files
+// against the docs root and Terraform files against the Terraform root
+func NewFileValidator(readmePath, docsRoot, terraformRoot string, additional ...string) *FileValidator {
+	files := []string{
+		readmePath,
+		filepath.Join(docsRoot, "CONTRIBUTING.md"),
+		filepath.Join(docsRoot, "CODE_OF_CONDUCT.md"),
+		filepath.Join(docsRoot, "SECURITY.md"),
+		filepath.Join(docsRoot, "LICENSE"),
+		filepath.Join(terraformRoot, "outputs.tf"),
+		filepath.Join(terraformRoot, "variables.tf"),
+		filepath.Join(terraformRoot, "terraform.tf"),
+		filepath.Join(docsRoot, "Makefile"),
+		filepath.Join(docsRoot, "TESTING.md"),
+	}
+	for _, name := range additional {
+		files = append(files, filepath.Join(docsRoot, name))
+	}
+	return &FileValidator{
+		files: files,
+		rules: make(map[string][]ContentRule),
+	}
+}
+
+// addContentRules attaches rules to a validated file given relative to one of the roots
+func (fv *FileValidator) addContentRules(name string, rules []ContentRule, roots ...string) error {
+	// Rules may be built without the constructors, so patterns are compiled here
+	compiled := make([]ContentRule, len(rules))
+	for i, rule := range rules {
+		switch rule.Kind {
+		case RuleHeading, RuleFencedBlock:
+		case RulePattern:
+			re, err := regexp.Compile("(?m)" + rule.Value)
+			if err != nil {
+				return fmt.Errorf("invalid content rule for %s: pattern %q does not compile: %v", name, rule.Value, err)
+			}
+			rule.pattern = re
+		default:
+			return fmt.Errorf("invalid content rule for %s: unknown kind %q", name, rule.Kind)
+		}
+		compiled[i] = rule
+	}
+	rules = compiled
+
+	for _, root := range roots {
+		path := filepath.Join(root, name)
+		for _, file := range fv.files {
+			if file == path {
+				fv.rules[path] = append(fv.rules[path], rules...)
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("content rules given for unknown file: %s", name)
+}
+
+// Validate checks if required files exist and are not empty, and satisfy their content rules
+func (fv *FileValidator) Validate() []error {
+	var allErrors []error
+	for _, filePath := range fv.files {
+		errs := validateFile(filePath)
+		if len(errs) == 0 && len(fv.rules[filePath]) > 0 {
+			errs = validateFileContent(filePath, fv.rules[filePath])
+		}
+		allErrors = append(allErrors, errs...)
+	}
+	return allErrors
+}
+
+// validateFile checks if a file exists and is not empty
+func validateFile(filePath string) []error {
+	var errors []error
+	fileInfo, err := os.Stat(filePath)
+	baseName := filepath.Base(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			errors = append(errors, formatError("file does not exist:\n  %s", baseName))
+		} else {
+			errors = append(errors, formatError("error accessing file:\n  %s\n  %v", baseName, err))
+		}
+		return errors
+	}
+
+	if fileInfo.Size() == 0 {
+		errors = append(errors, formatError("file is empty:\n  %s", baseName))
+	}
+
+	return errors
+}
+
+// ContentRuleKind is the kind of requirement a ContentRule places on a file
+type ContentRuleKind string
+
+const (
+	RuleHeading     ContentRuleKind = "heading"
+	RuleFencedBlock ContentRuleKind = "fenced block"
+	RulePattern     ContentRuleKind = "pattern"
+)
+
+// ContentRule is a requirement on the content of a documentation file
+type ContentRule struct {
+	Kind    ContentRuleKind
+	Value   string
+	pattern *regexp.Regexp
+}
+
+// MustContainHeading requires a heading with the given text at any level
+func MustContainHeading(text string) ContentRule {
+	return ContentRule{Kind: RuleHeading, Value: text}
+}
+
+// MustContainFencedBlock requires a fenced code block of the given language
+func MustContainFencedBlock(language string) ContentRule {
+	return ContentRule{Kind: RuleFencedBlock, Value: language}
+}
+
+// MustMatch requires the content to match the regular expression, compiled in multiline mode
+// when the rules are added to the validator
+func MustMatch(expr string) ContentRule {
+	return ContentRule{Kind: RulePattern, Value: expr}
+}
+
+// loadContentRules reads content rules from a JSON file mapping file names to their rules, e.g.
+// {"GOALS.md": [{"kind": "heading", "value": "Non-Goals"}]}
+func loadContentRules(path string) (map[string][]ContentRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read content rules: %v", err)
+	}
+
+	var rules map[string][]ContentRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse content rules in %s: %v", filepath.Base(path), err)
+	}
+	return rules, nil
+}
+
+// markdownLine is a heading text or fence language together with its line number, and
+// the heading level or fenced block content
+type markdownLine struct {
+	text  string
+	line  int
+	level int
+	body  string
+}
+
+// validateFileContent checks a file against its content rules
+func validateFileContent(filePath string, rules []ContentRule) []error {
+	baseName := filepath.Base(filePath)
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return []error{formatError("error accessing file:\n  %s\n  %v", baseName, err)}
+	}
+
+	headings, fences := scanMarkdownLines(string(content))
+
+	var errors []error
+	for _, rule := range rules {
+		if line, msg := rule.check(string(content), headings, fences); msg != "" {
+			errors = append(errors, formatError("file content rule failed:\n  %s:%d: %s", baseName, line, msg))
+		}
+	}
+	return errors
+}
+
+// check returns a description of why the content violates the rule, or an empty string, together
+// with the line closest to the violation. Rules without such a line report the first line.
+func (r ContentRule) check(content string, headings, fences []markdownLine) (int, string) {
+	switch r.Kind {
+	case RuleHeading:
+		var closest *markdownLine
+		for i, h := range headings {
+			if h.text == r.Value {
+				return 0, ""
+			}
+			if closest == nil && strings.EqualFold(h.text, r.Value) {
+				closest = &headings[i]
+			}
+		}
+		if closest != nil {
+			return closest.line, fmt.Sprintf("missing heading '%s', found '%s' instead", r.Value, closest.text)
+		}
+		return 1, fmt.Sprintf("missing heading '%s'", r.Value)
+
+	case RuleFencedBlock:
+		var found []string
+		for _, f := range fences {
+			if strings.EqualFold(f.text, r.Value) {
+				return 0, ""
+			}
+			language := f.text
+			if language == "" {
+				language = "no language"
+			}
+			found = append(found, fmt.Sprintf("%s (line %d)", language, f.line))
+		}
+		if len(found) > 0 {
+			return fences[0].line, fmt.Sprintf("missing fenced block of language '%s', found: %s", r.Value, strings.Join(found, ", "))
+		}
+		return 1, fmt.Sprintf("missing fenced block of language '%s'", r.Value)
+
+	case RulePattern:
+		if r.pattern.MatchString(content) {
+			return 0, ""
+		}
+		return 1, fmt.Sprintf("content does not match pattern '%s'", r.Value)
+	}
+
+	return 1, fmt.Sprintf("unknown content rule kind '%s'", r.Kind)
+}
+
+// scanMarkdownLines collects the headings and the opening fences of fenced code blocks,
+// ignoring anything inside fenced blocks. Setext headings are normalized to their ATX
+// equivalent, reported on the first line of the heading text.
+func scanMarkdownLines(content string) ([]markdownLine, []markdownLine) {
+	var headings, fences []markdownLine
+	var fence string
+	var paragraph markdownLine
+
+	for i, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+
+		previous := paragraph
+		paragraph = markdownLine{}
+
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+				fence = ""
+				continue
+			}
+			fences[len(fences)-1].body += line + "\n"
+			continue
+		}
+
+		if marker := fenceMarker(trimmed); marker != "" {
+			fence = marker
+			language := ""
+			if fields := strings.Fields(trimmed[len(marker):]); len(fields) > 0 {
+				language = fields[0]
+			}
+			fences = append(fences, markdownLine{text: language, line: i + 1})
+			continue
+		}
+
+		if previous.text != "" {
+			if level := setextLevel(trimmed); level > 0 {
+				headings = append(headings, markdownLine{text: previous.text, line: previous.line, level: level})
+				continue
+			}
+		}
+
+		// Lines indented four or more spaces are code, not ATX headings
+		level := len(trimmed) - len(strings.TrimLeft(trimmed, "#"))
+		if indentWidth(line) >= 4 {
+			level = 0
+		}
+		if level == 0 || level > 6 {
+			// Remember paragraph text that a setext underline on the next line would turn into a heading
+			switch {
+			case previous.text != "" && trimmed != "":
+				paragraph = markdownLine{text: previous.text + " " + trimmed, line: previous.line}
+			case trimmed != "" && !strings.ContainsAny(trimmed[:1], "|>-*+") && !isOrderedListItem(trimmed) && indentWidth(line) < 4:
+				paragraph = markdownLine{text: trimmed, line: i + 1}
+			}
+			continue
+		}
+		rest := trimmed[level:]
+		if rest != "" && rest[0] != ' ' && rest[0] != '\t' {
+			continue
+		}
+
+		text := strings.TrimSpace(rest)
+		if stripped := strings.TrimRight(text, "#"); stripped != text && (stripped == "" || strings.HasSuffix(stripped, " ")) {
+			text = strings.TrimSpace(stripped)
+		}
+		headings = append(headings, markdownLine{text: text, line: i + 1, level: level})
+	}
+
+	return headings, fences
+}
+
+// isOrderedListItem reports whether a trimmed line starts an ordered list item like "1. foo" or "2) bar"
+func isOrderedListItem(trimmed string) bool {
+	digits := len(trimmed) - len(strings.TrimLeft(trimmed, "0123456789"))
+	if digits == 0 || digits > 9 || digits == len(trimmed) || (trimmed[digits] != '.' && trimmed[digits] != ')') {
+		return false
+	}
+	rest := trimmed[digits+1:]
+	return rest == "" || rest[0] == ' ' || rest[0] == '\t'
+}
+
+// indentWidth returns the width of the leading whitespace of a line, counting a tab as four spaces
+func indentWidth(line string) int {
+	width := 0
+	for _, char := range line {
+		switch char {
+		case ' ':
+			width++
+		case '\t':
+			width += 4 - width%4
+		default:
+			return width
+		}
+	}
+	return width
+}
+
+// setextLevel returns the heading level a setext underline denotes, or 0 for other lines
+func setextLevel(line string) int {
+	switch {
+	case line == "":
+		return 0
+	case strings.Trim(line, "=") == "":
+		return 1
+	case strings.Trim(line, "-") == "":
+		return 2
+	}
+	return 0
+}
+
+// fenceMarker returns the run of backticks or tildes opening a fenced code block, if any
+func fenceMarker(line string) string {
+	for _, char := range []string{"`", "~"} {
+		run := len(line) - len(strings.TrimLeft(line, char))
+		if run >= 3 {
+			return line[:run]
+		}
+	}
+	return ""
+}
+
+// rxURL matches URLs with a scheme, compiled once as it is expensive to build
+var rxURL = xurls.Strict()
+
+// defaultConcurrentURLChecks is the number of URLs requested at the same time unless configured otherwise
+const defaultConcurrentURLChecks = 10
+
+// defaultMaxReadmeSize is the README size above which URLs are extracted line by line
+const defaultMaxReadmeSize = 1 << 20
+
+// URLValidator validates URLs in the markdown
+type URLValidator struct {
+	data         string
+	client       *http.Client
+	cache        *urlCache
+	changedLines []LineRange
+	onlyChanged  bool
+	lineScan     bool
+	severity     Severity
+	semaphore    chan struct{}
+	diffErr      error
+	checked      map[string]bool
+	skipped      map[string]bool
+	invalid      map[string]bool
+}
+
+// urlError is a failed check together with the URL it belongs to, used to order errors stably
+type urlError struct {
+	url string
+	err error
+}
+
+// urlCache memoizes liveness results so URLs shared between READMEs are checked once
+type urlCache struct {
+	mu      sync.Mutex
+	results map[string]*urlResult
+}
+
+// urlResult is the memoized outcome of a single liveness check
+type urlResult struct {
+	once sync.Once
+	err  error
+}
+
+// newURLCache creates an empty urlCache
+func newURLCache() *urlCache {
+	return &urlCache{results: make(map[string]*urlResult)}
+}
+
+// check validates the URL unless it was checked before, returning the memoized result otherwise
+func (c *urlCache) check(client *http.Client, rawURL string) error {
+	c.mu.Lock()
+	result, ok := c.results[rawURL]
+	if !ok {
+		result = &urlResult{}
+		c.results[rawURL] = result
+	}
+	c.mu.Unlock()
+
+	result.once.Do(func() {
+		result.err = validateSingleURL(client, rawURL)
+	})
+	return result.err
+}
+
+// urlMatch is a URL found in the markdown together with its line number
+type urlMatch struct {
+	url  string
+	line int
+}
+
+// NewURLValidator creates a new URLValidator
+func NewURLValidator(data string, client *http.Client) *URLValidator {
+	return &URLValidator{
+		data:      data,
+		client:    client,
+		cache:     newURLCache(),
+		severity:  SeverityError,
+		semaphore: make(chan struct{}, defaultConcurrentURLChecks),
+	}
+}
+
+// RestrictToLines limits liveness checks to URLs occurring on the given lines,
+// all other URLs only get syntactic validation
+func (uv *URLValidator) RestrictToLines(ranges []LineRange) {
+	uv.changedLines = ranges
+	uv.onlyChanged = true
+}
+
+// Validate checks all URLs in the markdown for accessibility
+func (uv *URLValidator) Validate() []error {
+	// Code and comments hold example snippets with placeholder URLs rather than links
+	data := maskMarkdownCode(uv.data)
+
+	var matches []urlMatch
+	if uv.lineScan {
+		matches = extractURLsByLine(data)
+	} else {
+		matches = extractURLs(data)
+	}
+
+	var wg sync.WaitGroup
+	errChan := make(chan urlError, len(matches))
+	uv.checked, uv.skipped, uv.invalid = make(map[string]bool), make(map[string]bool), make(map[string]bool)
+
+	for _, m := range matches {
+		u, line := m.url, m.line
+
+		if err := validateURLSyntax(u); err != nil {
+			uv.invalid[u] = true
+			errChan <- urlError{url: u, err: err}
+			continue
+		}
+
+		if strings.Contains(u, "registry.terraform.io/providers/") {
+			uv.skipped[u] = true
+			continue
+		}
+
+		if uv.onlyChanged && !linesContain(uv.changedLines, line) {
+			uv.skipped[u] = true
+			continue
+		}
+
+		uv.checked[u] = true
+		wg.Add(1)
+		go func(url string) {
+			defer wg.Done()
+			uv.semaphore <- struct{}{}
+			defer func() { <-uv.semaphore }()
+			if err := uv.cache.check(uv.client, url); err != nil {
+				errChan <- urlError{url: url, err: &ValidationError{Check: CheckURLLiveness, Severity: uv.severity, Err: err}}
+			}
+		}(u)
+	}
+
+	wg.Wait()
+	close(errChan)
+
+	var collected []urlError
+	for e := range errChan {
+		collected = append(collected, e)
+	}
+
+	// Goroutines finish in any order, sorting keeps the output identical between runs
+	sort.Slice(collected, func(i, j int) bool {
+		if collected[i].url != collected[j].url {
+			return collected[i].url < collected[j].url
+		}
+		return collected[i].err.Error() < collected[j].err.Error()
+	})
+
+	errors := make([]error, 0, len(collected)+1)
+	if uv.diffErr != nil {
+		errors = append(errors, &ValidationError{
+			Check:    CheckChangedLines,
+			Severity: SeverityWarning,
+			Err:      fmt.Errorf("changed lines unavailable, checked all URLs: %v", uv.diffErr),
+		})
+	}
+	for _, e := range collected {
+		errors = append(errors, e.err)
+	}
+
+	return errors
+}
+
+// extractURLs finds all URLs in the data with a single scan over the whole document
+func extractURLs(data string) []urlMatch {
+	var matches []urlMatch
+
+	line, lineOffset := 1, 0
+	for _, m := range rxURL.FindAllStringIndex(data, -1) {
+		line += strings.Count(data[lineOffset:m[0]], "\n")
+		lineOffset = m[0]
+		matches = append(matches, urlMatch{url: data[m[0]:m[1]], line: line})
+	}
+
+	return matches
+}
+
+// extractURLsByLine finds all URLs in the data line by line, only running the expensive
+// URL expression on lines that can contain a match. It finds the same URLs as extractURLs,
+// since URLs never span lines and every strict match needs a scheme separator.
+func extractURLsByLine(data string) []urlMatch {
+	var matches []urlMatch
+
+	line := 0
+	for len(data) > 0 {
+		line++
+
+		text := data
+		if i := strings.IndexByte(data, '\n'); i >= 0 {
+			text, data = data[:i], data[i+1:]
+		} else {
+			data = ""
+		}
+
+		if !mayContainURL(text) {
+			continue
+		}
+		for _, u := range rxURL.FindAllString(text, -1) {
+			matches = append(matches, urlMatch{url: u, line: line})
+		}
+	}
+
+	return matches
+}
+
+// maskMarkdownCode blanks fenced code blocks, inline code spans and HTML comments. Masked bytes
+// become spaces and newlines are kept, so offsets and line numbers stay the same.
+func maskMarkdownCode(data string) string {
+	masked := []byte(data)
+
+	fence, fenceStart, textStart := "", 0, 0
+	for offset := 0; offset < len(data); {
+		end := len(data)
+		if i := strings.IndexByte(data[offset:], '\n'); i >= 0 {
+			end = offset + i + 1
+		}
+		trimmed := strings.TrimSpace(data[offset:end])
+
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+				blankBytes(masked, fenceStart, end)
+				fence, textStart = "", end
+			}
+		} else if marker := fenceMarker(trimmed); marker != "" {
+			maskInlineCode(masked, data, textStart, offset)
+			fence, fenceStart = marker, offset
+		}
+		offset = end
+	}
+
+	// An unclosed fence runs to the end of the document
+	if fence != "" {
+		blankBytes(masked, fenceStart, len(data))
+	} else {
+		maskInlineCode(masked, data, textStart, len(data))
+	}
+
+	return string(masked)
+}
+
+// maskInlineCode blanks the code spans and HTML comments between start and end. Code spans close
+// on a backtick run of the same length within the paragraph, otherwise the backticks are literal.
+func maskInlineCode(masked []byte, data string, start, end int) {
+	for i := start; i < end; {
+		switch {
+		case strings.HasPrefix(data[i:end], "<!--"):
+			comment := strings.Index(data[i+4:end], "-->")
+			if comment < 0 {
+				i += 4
+				continue
+			}
+			comment += i + 4 + 3
+			blankBytes(masked, i, comment)
+			i = comment
+
+		case data[i] == '`':
+			run := len(data[i:end]) - len(strings.TrimLeft(data[i:end], "`"))
+			limit := end
+			if blank := strings.Index(data[i:end], "\n\n"); blank >= 0 {
+				limit = i + blank
+			}
+
+			closing := -1
+			for j := i + run; j < limit; {
+				if data[j] != '`' {
+					j++
+					continue
+				}
+				n := len(data[j:limit]) - len(strings.TrimLeft(data[j:limit], "`"))
+				if n == run {
+					closing = j + n
+					break
+				}
+				j += n
+			}
+
+			if closing < 0 {
+				i += run
+				continue
+			}
+			blankBytes(masked, i, closing)
+			i = closing
+
+		default:
+			i++
+		}
+	}
+}
+
+// blankBytes replaces the bytes between start and end with spaces, keeping newlines
+func blankBytes(b []byte, start, end int) {
+	for i := start; i < end; i++ {
+		if b[i] != '\n' {
+			b[i] = ' '
+		}
+	}
+}
+
+// mayContainURL is a cheap check whether a line can hold a strict URL match
+func mayContainURL(text string) bool {
+	if strings.Contains(text, "://") {
+		return true
+	}
+	if !strings.Contains(text, ":") {
+		return false
+	}
+
+	lower := strings.ToLower(text)
+	for _, scheme := range xurls.SchemesNoAuthority {
+		if strings.Contains(lower, scheme+":") {
+			return true
+		}
+	}
+	return false
+}
+
+// validateURLSyntax checks if a URL is a well-formed absolute http(s) URL
+func validateURLSyntax(rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return formatError("malformed URL:\n  %s\n  %v", rawURL, err)
+	}
+	if (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return formatError("malformed URL:\n  %s\n  expected an absolute http or https URL", rawURL)
+	}
+	return nil
+}
+
+// LineRange is an inclusive range of line numbers
+type LineRange struct {
+	Start int
+	End   int
+}
+
+// Contains reports whether the line falls within the range
+func (r LineRange) Contains(line int) bool {
+	return line >= r.Start && line <= r.End
+}
+
+// linesContain reports whether any of the ranges contains the line
+func linesContain(ranges []LineRange, line int) bool {
+	for _, r := range ranges {
+		if r.Contains(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseUnifiedDiff returns the line ranges added or modified in the given file, which is
+// matched by comparing the diff's target path against the end of the file's path. Only the added
+// lines of a hunk are recorded, context lines of diffs made without -U0 are not.
+func parseUnifiedDiff(r io.Reader, filePath string) ([]LineRange, error) {
+	target := filepath.ToSlash(filePath)
+
+	var ranges []LineRange
+	inTarget := false
+
+	// line is the next new-file line of the current hunk, remaining the number of new-file lines left in it
+	line, remaining := 0, 0
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		text := scanner.Text()
+
+		// Hunk bodies are consumed first, so an added line starting with "++" isn't taken for a file header
+		if remaining > 0 {
+			switch {
+			case strings.HasPrefix(text, "+"):
+				if inTarget {
+					ranges = addChangedLine(ranges, line)
+				}
+				line++
+				remaining--
+				continue
+			case strings.HasPrefix(text, " "), text == "":
+				line++
+				remaining--
+				continue
+			case strings.HasPrefix(text, "-"), strings.HasPrefix(text, "\\"):
+				continue
+			}
+			// Anything else ends a truncated hunk
+			remaining = 0
+		}
+
+		switch {
+		case strings.HasPrefix(text, "+++ "):
+			name := strings.TrimSpace(strings.TrimPrefix(text, "+++ "))
+			if tab := strings.IndexByte(name, '\t'); tab >= 0 {
+				name = name[:tab]
+			}
+			name = strings.TrimPrefix(name, "b/")
+			inTarget = name != "/dev/null" && (target == name || strings.HasSuffix(target, "/"+name))
+
+		case strings.HasPrefix(text, "@@ "):
+			hunk, ok := parseHunkHeader(text)
+			if !ok {
+				if inTarget {
+					return nil, fmt.Errorf("malformed hunk header: %s", text)
+				}
+				continue
+			}
+			line, remaining = hunk.Start, hunk.End-hunk.Start+1
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read diff: %v", err)
+	}
+
+	return ranges, nil
+}
+
+// addChangedLine adds a line to the ranges, extending the last range when the line follows it
+func addChangedLine(ranges []LineRange, line int) []LineRange {
+	if n := len(ranges); n > 0 && ranges[n-1].End == line-1 {
+		ranges[n-1].End = line
+		return ranges
+	}
+	return append(ranges, LineRange{Start: line, End: line})
+}
+
+// parseHunkHeader extracts the new-file line range from a hunk header like "@@ -1,2 +3,4 @@"
+func parseHunkHeader(header string) (LineRange, bool) {
+	fields := strings.Fields(header)
+	if len(fields) < 3 || !strings.HasPrefix(fields[2], "+") {
+		return LineRange{}, false
+	}
+
+	start, count := strings.TrimPrefix(fields[2], "+"), "1"
+	if comma := strings.IndexByte(start, ','); comma >= 0 {
+		start, count = start[:comma], start[comma+1:]
+	}
+
+	first, err := strconv.Atoi(start)
+	if err != nil {
+		return LineRange{}, false
+	}
+	n, err := strconv.Atoi(count)
+	if err != nil {
+		return LineRange{}, false
+	}
+
+	// A count of zero denotes a pure deletion, which yields an empty range
+	return LineRange{Start: first, End: first + n - 1}, true
+}
+
+// validateSingleURL checks if a single URL is accessible
+func validateSingleURL(client *http.Client, url string) error {
+	resp, err := client.Get(url)
+	if err != nil {
+		return formatError("error accessing URL:\n  %s\n  %v", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return formatError("URL returned non-OK status:\n  %s\n  Status: %d", url, resp.StatusCode)
+	}
+
+	return nil
+}
+
+// ExampleDocsValidator validates that examples document their usage in a README
+type ExampleDocsValidator struct {
+	examplesDir string
+	skip        []string
+}
+
+// NewExampleDocsValidator creates a new ExampleDocsValidator
+func NewExampleDocsValidator(examplesDir string, skip []string) *ExampleDocsValidator {
+	return &ExampleDocsValidator{examplesDir: examplesDir, skip: skip}
+}
+
+// Validate checks the README of every example that is not skipped
+func (ev *ExampleDocsValidator) Validate() []error {
+	dirs, err := exampleDirs(ev.examplesDir, ev.skip)
+	if err != nil {
+		return []error{err}
+	}
+
+	var errors []error
+	for _, dir := range dirs {
+		errors = append(errors, validateExampleDocs(dir)...)
+	}
+	return errors
+}
+
+// exampleDirs returns the example directories whose name matches none of the skip patterns
+func exampleDirs(examplesDir string, skip []string) ([]string, error) {
+	entries, err := os.ReadDir(examplesDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read examples directory: %v", err)
+	}
+
+	var dirs []string
+	for _, entry := range entries {
+		if !entry.IsDir() || skipExample(entry.Name(), skip) {
+			continue
+		}
+		dirs = append(dirs, filepath.Join(examplesDir, entry.Name()))
+	}
+	return dirs, nil
+}
+
+// skipExample reports whether the example matches any of the skip patterns
+func skipExample(name string, skip []string) bool {
+	for _, pattern := range skip {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ExampleProvidersValidator validates the provider configuration of examples
+type ExampleProvidersValidator struct {
+	examplesDir string
+	skip        []string
+}
+
+// NewExampleProvidersValidator creates a new ExampleProvidersValidator
+func NewExampleProvidersValidator(examplesDir string, skip []string) *ExampleProvidersValidator {
+	return &ExampleProvidersValidator{examplesDir: examplesDir, skip: skip}
+}
+
+// Validate checks the provider blocks of every example that is not skipped
+func (pv *ExampleProvidersValidator) Validate() []error {
+	dirs, err := exampleDirs(pv.examplesDir, pv.skip)
+	if err != nil {
+		return []error{err}
+	}
+
+	var errors []error
+	for _, dir := range dirs {
+		errors = append(errors, validateExampleProviders(dir)...)
+	}
+	return errors
+}
+
+// providerRef is a provider configuration, e.g. azurerm or azurerm.secondary, and the file it is declared or used in
+type providerRef struct {
+	key  string
+	file string
+}
+
+// validateExampleProviders checks that azurerm providers have a features block, that every
+// provider configuration passed to a module or resource is declared, and that every declared
+// provider configuration is used. Modules without a providers argument inherit the default ones.
+func validateExampleProviders(exampleDir string) []error {
+	name := filepath.Base(exampleDir)
+
+	files, err := filepath.Glob(filepath.Join(exampleDir, "*.tf"))
+	if err != nil || len(files) == 0 {
+		return nil
+	}
+	sort.Strings(files)
+
+	var errors []error
+	var declared, used []providerRef
+	inheritsDefaults := false
+
+	parser := hclparse.NewParser()
+	for _, path := range files {
+		file, diags := parser.ParseHCLFile(path)
+		if diags.HasErrors() {
+			return []error{fmt.Errorf("error parsing HCL in %s: %v", filepath.Base(path), diags)}
+		}
+		body, ok := file.Body.(*hclsyntax.Body)
+		if !ok {
+			continue
+		}
+		base := filepath.Base(path)
+		content := file.Bytes
+
+		for _, block := range body.Blocks {
+			switch {
+			case block.Type == "provider" && len(block.Labels) == 1:
+				key := block.Labels[0]
+				if attr, ok := block.Body.Attributes["alias"]; ok {
+					key += "." + strings.Trim(strings.TrimSpace(string(attr.Expr.Range().SliceBytes(content))), `"`)
+				}
+				declared = append(declared, providerRef{key: key, file: base})
+
+				if block.Labels[0] == "azurerm" && !hasBlock(block.Body, "features") {
+					errors = append(errors, formatError("example providers:\n  %s\n  %s: provider '%s' has no features block", name, base, key))
+				}
+
+			case block.Type == "module":
+				attr, ok := block.Body.Attributes["providers"]
+				if !ok {
+					inheritsDefaults = true
+					continue
+				}
+				if object, ok := attr.Expr.(*hclsyntax.ObjectConsExpr); ok {
+					for _, item := range object.Items {
+						if key := providerKey(item.ValueExpr); key != "" {
+							used = append(used, providerRef{key: key, file: base})
+						}
+					}
+				}
+
+			case (block.Type == "resource" || block.Type == "data") && len(block.Labels) == 2:
+				if attr, ok := block.Body.Attributes["provider"]; ok {
+					if key := providerKey(attr.Expr); key != "" {
+						used = append(used, providerRef{key: key, file: base})
+					}
+					continue
+				}
+				if prefix, _, ok := strings.Cut(block.Labels[0], "_"); ok {
+					used = append(used, providerRef{key: prefix, file: base})
+				}
+			}
+		}
+	}
+
+	isDeclared := make(map[string]bool, len(declared))
+	for _, p := range declared {
+		isDeclared[p.key] = true
+	}
+	isUsed := make(map[string]bool, len(used))
+	for _, p := range used {
+		// Default configurations are implicit, only missing aliases are an error
+		if strings.Contains(p.key, ".") && !isDeclared[p.key] {
+			errors = append(errors, formatError("example providers:\n  %s\n  %s: provider '%s' is used but not declared", name, p.file, p.key))
+		}
+		isUsed[p.key] = true
+	}
+
+	for _, p := range declared {
+		if isUsed[p.key] || (inheritsDefaults && !strings.Contains(p.key, ".")) {
+			continue
+		}
+		errors = append(errors, formatError("example providers:\n  %s\n  %s: provider '%s' is declared but never used", name, p.file, p.key))
+	}
+
+	return errors
+}
+
+// providerKey returns the provider configuration an expression like azurerm.secondary refers to
+func providerKey(expr hcl.Expression) string {
+	traversal, diags := hcl.AbsTraversalForExpr(expr)
+	if diags.HasErrors() {
+		return ""
+	}
+	key := traversal.RootName()
+	if len(traversal) > 1 {
+		if attr, ok := traversal[1].(hcl.TraverseAttr); ok {
+			key += "." + attr.Name
+		}
+	}
+	return key
+}
+
+// hasBlock reports whether a body contains a nested block of the given type
+func hasBlock(body *hclsyntax.Body, blockType string) bool {
+	for _, block := range body.Blocks {
+		if block.Type == blockType {
+			return true
+		}
+	}
+	return false
+}
+
+// validateExampleDocs checks that an example README has a title and a fenced HCL block, and
+// that module sources shown in the README match the module calls in the example's main.tf
+func validateExampleDocs(exampleDir string) []error {
+	name := filepath.Base(exampleDir)
+
+	content, err := os.ReadFile(filepath.Join(exampleDir, "README.md"))
+	if os.IsNotExist(err) {
+		return []error{formatError("example documentation:\n  %s\n  README.md does not exist", name)}
+	} else if err != nil {
+		return []error{formatError("example documentation:\n  %s\n  %v", name, err)}
+	}
+
+	headings, fences := scanMarkdownLines(string(content))
+
+	var errors []error
+	if len(headings) == 0 {
+		errors = append(errors, formatError("example documentation:\n  %s\n  README.md has no title", name))
+	}
+	if len(fences) == 0 {
+		return append(errors, formatError("example documentation:\n  %s\n  README.md has no code fence", name))
+	}
+
+	var hclFences []markdownLine
+	for _, f := range fences {
+		switch strings.ToLower(f.text) {
+		case "hcl", "terraform", "tf":
+			hclFences = append(hclFences, f)
+		}
+	}
+	if len(hclFences) == 0 {
+		return append(errors, formatError("example documentation:\n  %s\n  README.md has no fenced HCL block", name))
+	}
+
+	mainPath := filepath.Join(exampleDir, "main.tf")
+	mainContent, err := os.ReadFile(mainPath)
+	if err != nil {
+		return append(errors, formatError("example documentation:\n  %s\n  %v", name, err))
+	}
+	actual, err := extractModuleSources(mainContent, mainPath)
+	if err != nil {
+		return append(errors, err)
+	}
+
+	known := make(map[string]bool, len(actual))
+	for _, source := range actual {
+		known[normalizeModuleSource(source)] = true
+	}
+
+	for _, f := range hclFences {
+		// Snippets that are not valid HCL cannot be compared and are left alone
+		documented, err := extractModuleSources([]byte(f.body), filepath.Join(exampleDir, "README.md"))
+		if err != nil {
+			continue
+		}
+		for _, source := range documented {
+			if !known[normalizeModuleSource(source)] {
+				errors = append(errors, formatError("example documentation:\n  %s\n  module source '%s' in README.md (line %d) does not match main.tf: %s",
+					name, source, f.line, strings.Join(actual, ", ")))
+			}
+		}
+	}
+
+	return errors
+}
+
+// extractModuleSources returns the source of every module call in the given HCL content
+func extractModuleSources(content []byte, filePath string) ([]string, error) {
+	parser := hclparse.NewParser()
+	file, parseDiags := parser.ParseHCL(content, filePath)
+	if parseDiags.HasErrors() {
+		return nil, fmt.Errorf("error parsing HCL in %s: %v", filepath.Base(filePath), parseDiags)
+	}
+
+	hclContent, _, _ := file.Body.PartialContent(&hcl.BodySchema{
+		Blocks: []hcl.BlockHeaderSchema{
+			{Type: "module", LabelNames: []string{"name"}},
+		},
+	})
+	if hclContent == nil {
+		return nil, nil
+	}
+
+	var sources []string
+	for _, block := range hclContent.Blocks {
+		attrs, _, _ := block.Body.PartialContent(&hcl.BodySchema{
+			Attributes: []hcl.AttributeSchema{{Name: "source"}},
+		})
+		if attr, ok := attrs.Attributes["source"]; ok {
+			source := strings.TrimSpace(string(attr.Expr.Range().SliceBytes(content)))
+			sources = append(sources, strings.Trim(source, `"`))
+		}
+	}
+	return sources, nil
+}
+
+// normalizeModuleSource loosens a module source for comparison, ignoring case, git and
+// scheme prefixes, ref queries and trailing slashes
+func normalizeModuleSource(source string) string {
+	source = strings.ToLower(strings.TrimSpace(source))
+	source = strings.TrimPrefix(source, "git::")
+	for _, scheme := range []string{"https://", "http://", "ssh://"} {
+		source = strings.TrimPrefix(source, scheme)
+	}
+	if i := strings.IndexByte(source, '?'); i >= 0 {
+		source = source[:i]
+	}
+	source = strings.TrimSuffix(source, ".git")
+	return strings.TrimRight(source, "/")
+}
+
+// VariableUsageValidator validates that declared variables and var references match up
+type VariableUsageValidator struct {
+	rootDir string
+}
+
+// NewVariableUsageValidator creates a new VariableUsageValidator
+func NewVariableUsageValidator(rootDir string) *VariableUsageValidator {
+	return &VariableUsageValidator{rootDir: rootDir}
+}
+
+// Validate checks the root module and every module under its modules directory
+func (vv *VariableUsageValidator) Validate() []error {
+	return validateModules(vv.rootDir, validateVariableUsage)
+}
+
+// DynamicReferenceValidator validates that dynamic block for_each expressions refer to declarations
+type DynamicReferenceValidator struct {
+	rootDir string
+}
+
+// NewDynamicReferenceValidator creates a new DynamicReferenceValidator
+func NewDynamicReferenceValidator(rootDir string) *DynamicReferenceValidator {
+	return &DynamicReferenceValidator{rootDir: rootDir}
+}
+
+// Validate checks the root module and every module under its modules directory
+func (dv *DynamicReferenceValidator) Validate() []error {
+	return validateModules(dv.rootDir, validateDynamicReferences)
+}
+
+// OutputDescriptionValidator validates that outputs describe what they return
+type OutputDescriptionValidator struct {
+	rootDir string
+}
+
+// NewOutputDescriptionValidator creates a new OutputDescriptionValidator
+func NewOutputDescriptionValidator(rootDir string) *OutputDescriptionValidator {
+	return &OutputDescriptionValidator{rootDir: rootDir}
+}
+
+// Validate checks the root module and every module under its modules directory
+func (ov *OutputDescriptionValidator) Validate() []error {
+	return validateModules(ov.rootDir, validateOutputDescriptions)
+}
+
+// declaredVariable is a variable block together with the attributes reviewers look for
+type declaredVariable struct {
+	name           string
+	hasDefault     bool
+	hasDescription bool
+}
+
+// moduleFile is a parsed Terraform file of a module
+type moduleFile struct {
+	name string
+	body *hclsyntax.Body
+}
+
+// moduleIndex holds the declarations of a module and the variables it references
+type moduleIndex struct {
+	name         string
+	files        []moduleFile
+	variables    []declaredVariable
+	declarations map[string]bool
+	varRefs      []string
+}
+
+// validateModules indexes the root module and every module under its modules directory and runs
+// the check on each of them
+func validateModules(rootDir string, check func(*moduleIndex) []error) []error {
+	if _, err := os.Stat(rootDir); os.IsNotExist(err) {
+		return nil
+	}
+
+	dirs := []string{rootDir}
+	modulesDir := filepath.Join(rootDir, "modules")
+	err := filepath.WalkDir(modulesDir, func(path string, d os.DirEntry, err error) error {
+		if os.IsNotExist(err) && path == modulesDir {
+			return filepath.SkipDir
+		} else if err != nil {
+			return err
+		}
+		if d.IsDir() && path != modulesDir {
+			if strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			dirs = append(dirs, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return []error{fmt.Errorf("failed to search modules: %v", err)}
+	}
+
+	var errors []error
+	for _, dir := range dirs {
+		index, err := indexModule(rootDir, dir)
+		if err != nil {
+			errors = append(errors, err)
+			continue
+		}
+		if index != nil {
+			errors = append(errors, check(index)...)
+		}
+	}
+	return errors
+}
+
+// indexModule parses the Terraform files of a module directory and records its declarations, keyed
+// the way expressions refer to them: var.x, local.x, module.x, data.type.name and type.name.
+// It returns nil for directories without Terraform files.
+func indexModule(rootDir, dir string) (*moduleIndex, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.tf"))
+	if err != nil || len(paths) == 0 {
+		return nil, nil
+	}
+	sort.Strings(paths)
+
+	index := &moduleIndex{name: "root", declarations: make(map[string]bool)}
+	if rel, err := filepath.Rel(rootDir, dir); err == nil && rel != "." {
+		index.name = filepath.ToSlash(rel)
+	}
+
+	referenced := make(map[string]bool)
+	parser := hclparse.NewParser()
+	for _, path := range paths {
+		file, diags := parser.ParseHCLFile(path)
+		if diags.HasErrors() {
+			return nil, fmt.Errorf("error parsing HCL in %s: %v", filepath.Base(path), diags)
+		}
+		body, ok := file.Body.(*hclsyntax.Body)
+		if !ok {
+			continue
+		}
+		index.files = append(index.files, moduleFile{name: filepath.Base(path), body: body})
+
+		for _, block := range body.Blocks {
+			switch {
+			case block.Type == "variable" && len(block.Labels) == 1:
+				_, hasDefault := block.Body.Attributes["default"]
+				_, hasDescription := block.Body.Attributes["description"]
+				index.variables = append(index.variables, declaredVariable{name: block.Labels[0], hasDefault: hasDefault, hasDescription: hasDescription})
+				index.declarations["var."+block.Labels[0]] = true
+			case block.Type == "locals":
+				for name := range block.Body.Attributes {
+					index.declarations["local."+name] = true
+				}
+			case block.Type == "module" && len(block.Labels) == 1:
+				index.declarations["module."+block.Labels[0]] = true
+			case block.Type == "data" && len(block.Labels) == 2:
+				index.declarations["data."+block.Labels[0]+"."+block.Labels[1]] = true
+			case block.Type == "resource" && len(block.Labels) == 2:
+				index.declarations[block.Labels[0]+"."+block.Labels[1]] = true
 			}
-		}
-		return ast.GoToNext
-	})
 
-	if !found {
-		errors = append(errors, compareHeaders(s.Header, ""))
+			// A variable's own validation conditions don't count as a use of it
+			if block.Type == "variable" {
+				continue
+			}
+			hclsyntax.VisitAll(block, func(node hclsyntax.Node) hcl.Diagnostics {
+				expr, ok := node.(*hclsyntax.ScopeTraversalExpr)
+				if !ok || expr.Traversal.RootName() != "var" || len(expr.Traversal) < 2 {
+					return nil
+				}
+				if attr, ok := expr.Traversal[1].(hcl.TraverseAttr); ok && !referenced[attr.Name] {
+					referenced[attr.Name] = true
+					index.varRefs = append(index.varRefs, attr.Name)
+				}
+				return nil
+			})
+		}
 	}
 
-	return errors
+	return index, nil
 }
 
-func validateColumns(header string, required, optional, actual []string) []error {
+// validateVariableUsage compares the variables declared in a module with the var references in
+// all of its Terraform files, as variables are commonly used in locals and outputs too. Unused
+// variables are reported as warnings, references to undeclared ones as errors.
+func validateVariableUsage(index *moduleIndex) []error {
 	var errors []error
 
-	// Create a map of valid columns
-	validColumns := make(map[string]bool)
-	for _, col := range required {
-		validColumns[col] = true
-	}
-	for _, col := range optional {
-		validColumns[col] = true
+	referenced := make(map[string]bool, len(index.varRefs))
+	for _, name := range index.varRefs {
+		referenced[name] = true
 	}
 
-	// Track found and invalid columns
-	foundColumns := make(map[string]bool)
-	hasInvalidColumns := false
+	var unused []string
+	for _, v := range index.variables {
+		if referenced[v.name] {
+			continue
+		}
 
-	// First check for unexpected columns
-	for _, act := range actual {
-		if !validColumns[act] {
-			hasInvalidColumns = true
-			errors = append(errors, formatError("unexpected column '%s' in table under header: %s", act, header))
+		var notes []string
+		if !v.hasDefault {
+			notes = append(notes, "required")
+		}
+		if !v.hasDescription {
+			notes = append(notes, "no description")
+		}
+		if len(notes) > 0 {
+			unused = append(unused, fmt.Sprintf("%s (%s)", v.name, strings.Join(notes, ", ")))
+		} else {
+			unused = append(unused, v.name)
 		}
-		foundColumns[act] = true
+	}
+	if len(unused) > 0 {
+		errors = append(errors, &ValidationError{
+			Check:    CheckVariableUsage,
+			Severity: SeverityWarning,
+			Err:      formatError("variables declared but never referenced in %s:\n  %s", index.name, strings.Join(unused, "\n  ")),
+		})
 	}
 
-	// Only check for missing required columns if there were no invalid columns
-	if !hasInvalidColumns {
-		for _, req := range required {
-			if !foundColumns[req] {
-				errors = append(errors, formatError("missing required column '%s' in table under header: %s", req, header))
-			}
+	var undeclared []string
+	for _, name := range index.varRefs {
+		if !index.declarations["var."+name] {
+			undeclared = append(undeclared, name)
 		}
 	}
+	if len(undeclared) > 0 {
+		errors = append(errors, &ValidationError{
+			Check:    CheckVariableUsage,
+			Severity: SeverityError,
+			Err:      formatError("variables referenced but not declared in %s:\n  %s", index.name, strings.Join(undeclared, "\n  ")),
+		})
+	}
 
 	return errors
 }
 
-// getNextSibling returns the next sibling of a node
-func getNextSibling(node ast.Node) ast.Node {
-	parent := node.GetParent()
-	if parent == nil {
-		return nil
-	}
-	children := parent.GetChildren()
-	for i, n := range children {
-		if n == node && i+1 < len(children) {
-			return children[i+1]
+// validateOutputDescriptions reports every output block of a module whose description is missing
+// or only whitespace. Descriptions that are not a constant string are taken as present.
+func validateOutputDescriptions(index *moduleIndex) []error {
+	var errors []error
+	for _, f := range index.files {
+		for _, block := range f.body.Blocks {
+			if block.Type != "output" || len(block.Labels) != 1 {
+				continue
+			}
+			if attr, ok := block.Body.Attributes["description"]; ok && !blankString(attr.Expr) {
+				continue
+			}
+			errors = append(errors, &ValidationError{
+				Check:    CheckOutputDescriptions,
+				Severity: SeverityWarning,
+				Err:      formatError("output without a description in %s:\n  %s:%d: %s", index.name, f.name, block.DefRange().Start.Line, block.Labels[0]),
+			})
 		}
 	}
-	return nil
+	return errors
 }
 
-// extractTableHeaders extracts headers from a markdown table
-func extractTableHeaders(table *ast.Table) ([]string, error) {
-	headers := []string{}
-
-	if len(table.GetChildren()) == 0 {
-		return nil, fmt.Errorf("table is empty")
+// blankString reports whether an expression is null or a constant string of only whitespace
+func blankString(expr hclsyntax.Expression) bool {
+	value, diags := expr.Value(nil)
+	if diags.HasErrors() || !value.IsWhollyKnown() {
+		return false
 	}
-
-	// The first child should be TableHeader
-	var headerNode *ast.TableHeader
-	for _, child := range table.GetChildren() {
-		if h, ok := child.(*ast.TableHeader); ok {
-			headerNode = h
-			break
-		}
+	if value.IsNull() {
+		return true
 	}
+	template, ok := expr.(*hclsyntax.TemplateExpr)
+	return ok && template.IsStringLiteral() && strings.TrimSpace(value.AsString()) == ""
+}
 
-	if headerNode == nil {
-		return nil, fmt.Errorf("table has no header row")
-	}
+// builtinReferences are the reference roots Terraform provides without a declaration
+var builtinReferences = map[string]bool{
+	"count":     true,
+	"each":      true,
+	"path":      true,
+	"self":      true,
+	"terraform": true,
+}
 
-	// The header row is under TableHeader
-	for _, rowNode := range headerNode.GetChildren() {
-		if row, ok := rowNode.(*ast.TableRow); ok {
-			for _, cellNode := range row.GetChildren() {
-				if cell, ok := cellNode.(*ast.TableCell); ok {
-					headerText := strings.TrimSpace(extractTextFromNodes(cell.GetChildren()))
-					headers = append(headers, headerText)
+// validateDynamicReferences checks that the for_each of every dynamic block only refers to declared
+// variables, locals, modules, data sources and resources, or to the iterator of an enclosing
+// dynamic block. References wrapped in functions like try() are checked too.
+func validateDynamicReferences(index *moduleIndex) []error {
+	var errors []error
+
+	var walk func(file string, body *hclsyntax.Body, iterators map[string]bool)
+	walk = func(file string, body *hclsyntax.Body, iterators map[string]bool) {
+		for _, block := range body.Blocks {
+			if block.Type != "dynamic" || len(block.Labels) != 1 {
+				walk(file, block.Body, iterators)
+				continue
+			}
+
+			if attr, ok := block.Body.Attributes["for_each"]; ok {
+				for _, traversal := range attr.Expr.Variables() {
+					root := traversal.RootName()
+					if builtinReferences[root] || iterators[root] {
+						continue
+					}
+					ref := referenceKey(traversal)
+					if index.declarations[ref] {
+						continue
+					}
+
+					msg := fmt.Sprintf("%s:%d: %s in dynamic \"%s\" for_each is not declared",
+						file, traversal.SourceRange().Start.Line, ref, block.Labels[0])
+					if suggestion := nearestDeclaration(ref, index.declarations); suggestion != "" {
+						msg += fmt.Sprintf(", did you mean %s?", suggestion)
+					}
+					errors = append(errors, formatError("unresolved dynamic block reference in %s:\n  %s", index.name, msg))
+				}
+			}
+
+			iterator := block.Labels[0]
+			if attr, ok := block.Body.Attributes["iterator"]; ok {
+				if name := hcl.ExprAsKeyword(attr.Expr); name != "" {
+					iterator = name
 				}
 			}
+			scope := make(map[string]bool, len(iterators)+1)
+			for name := range iterators {
+				scope[name] = true
+			}
+			scope[iterator] = true
+			walk(file, block.Body, scope)
 		}
 	}
 
-	return headers, nil
-}
-
-// FileValidator validates the presence of required files
-type FileValidator struct {
-	files []string
+	for _, f := range index.files {
+		walk(f.name, f.body, nil)
+	}
+	return errors
 }
 
-func NewFileValidator(readmePath string) *FileValidator {
-	rootDir := filepath.Dir(readmePath)
-	files := []string{
-		readmePath,
-		filepath.Join(rootDir, "CONTRIBUTING.md"),
-		filepath.Join(rootDir, "CODE_OF_CONDUCT.md"),
-		filepath.Join(rootDir, "SECURITY.md"),
-		filepath.Join(rootDir, "LICENSE"),
-		filepath.Join(rootDir, "outputs.tf"),
-		filepath.Join(rootDir, "variables.tf"),
-		filepath.Join(rootDir, "terraform.tf"),
-		filepath.Join(rootDir, "Makefile"),
-		filepath.Join(rootDir, "TESTING.md"),
+// referenceKey returns the part of a traversal naming a declaration, e.g. var.x or data.type.name
+func referenceKey(traversal hcl.Traversal) string {
+	parts := []string{traversal.RootName()}
+	size := 2
+	if parts[0] == "data" {
+		size = 3
 	}
-	return &FileValidator{
-		files: files,
+	for _, step := range traversal[1:] {
+		if len(parts) == size {
+			break
+		}
+		attr, ok := step.(hcl.TraverseAttr)
+		if !ok {
+			break
+		}
+		parts = append(parts, attr.Name)
 	}
+	return strings.Join(parts, ".")
 }
 
-// Validate checks if required files exist and are not empty
-func (fv *FileValidator) Validate() []error {
-	var allErrors []error
-	for _, filePath := range fv.files {
-		allErrors = append(allErrors, validateFile(filePath)...)
+// nearestDeclaration suggests the declaration closest to a misspelled reference, if any is close enough
+func nearestDeclaration(ref string, declarations map[string]bool) string {
+	best, bestDistance := "", len(ref)/3+1
+	for name := range declarations {
+		if d := editDistance(ref, name); d < bestDistance || (d == bestDistance && best != "" && name < best) {
+			best, bestDistance = name, d
+		}
 	}
-	return allErrors
+	return best
 }
 
-// validateFile checks if a file exists and is not empty
-func validateFile(filePath string) []error {
-	var errors []error
-	fileInfo, err := os.Stat(filePath)
-	baseName := filepath.Base(filePath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			errors = append(errors, formatError("file does not exist:\n  %s", baseName))
-		} else {
-			errors = append(errors, formatError("error accessing file:\n  %s\n  %v", baseName, err))
+// editDistance returns the Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
 		}
-		return errors
-	}
-
-	if fileInfo.Size() == 0 {
-		errors = append(errors, formatError("file is empty:\n  %s", baseName))
+		previous, current = current, previous
 	}
-
-	return errors
+	return previous[len(b)]
 }
 
-// URLValidator validates URLs in the markdown
-type URLValidator struct {
-	data string
+// terraformLayout describes the locations Terraform files may be committed in
+type terraformLayout struct {
+	scanRoot string
+	root     string
+	allowed  []string
 }
 
-// NewURLValidator creates a new URLValidator
-func NewURLValidator(data string) *URLValidator {
-	return &URLValidator{data: data}
+// allows reports whether a Terraform file in the given directory is part of the layout
+func (l terraformLayout) allows(dir string) bool {
+	if dir == l.root {
+		return true
+	}
+	for _, allowed := range l.allowed {
+		if dir == allowed || strings.HasPrefix(dir, allowed+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
 }
 
-// Validate checks all URLs in the markdown for accessibility
-func (uv *URLValidator) Validate() []error {
-	return validateURLs(uv.data)
+// StrayTerraformValidator flags Terraform files committed outside the expected layout
+type StrayTerraformValidator struct {
+	layout terraformLayout
 }
 
-// validateURLs checks if URLs in the data are accessible
-func validateURLs(data string) []error {
-	rxStrict := xurls.Strict()
-	urls := rxStrict.FindAllString(data, -1)
+// NewStrayTerraformValidator creates a new StrayTerraformValidator
+func NewStrayTerraformValidator(layout terraformLayout) *StrayTerraformValidator {
+	return &StrayTerraformValidator{layout: layout}
+}
 
-	var wg sync.WaitGroup
-	errChan := make(chan error, len(urls))
+// Validate lists Terraform files outside the Terraform root, modules and examples directories.
+// Hidden directories such as .terraform and Go testdata directories are not searched. Findings are
+// warnings, so repositories with other layouts keep passing until they allow their directories.
+func (sv *StrayTerraformValidator) Validate() []error {
+	if _, err := os.Stat(sv.layout.scanRoot); os.IsNotExist(err) {
+		return nil
+	}
 
-	for _, u := range urls {
-		if strings.Contains(u, "registry.terraform.io/providers/") {
-			continue
+	var stray []string
+	err := filepath.WalkDir(sv.layout.scanRoot, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
 		}
 
-		wg.Add(1)
-		go func(url string) {
-			defer wg.Done()
-			if err := validateSingleURL(url); err != nil {
-				errChan <- err
+		if d.IsDir() {
+			if path != sv.layout.scanRoot && (strings.HasPrefix(d.Name(), ".") || d.Name() == "testdata") {
+				return filepath.SkipDir
 			}
-		}(u)
-	}
-
-	wg.Wait()
-	close(errChan)
-
-	var errors []error
-	for err := range errChan {
-		errors = append(errors, err)
-	}
-
-	return errors
-}
+			return nil
+		}
 
-// validateSingleURL checks if a single URL is accessible
-func validateSingleURL(url string) error {
-	resp, err := http.Get(url)
+		if filepath.Ext(path) == ".tf" && !sv.layout.allows(filepath.Dir(path)) {
+			rel, err := filepath.Rel(sv.layout.scanRoot, path)
+			if err != nil {
+				rel = path
+			}
+			stray = append(stray, filepath.ToSlash(rel))
+		}
+		return nil
+	})
 	if err != nil {
-		return formatError("error accessing URL:\n  %s\n  %v", url, err)
+		return []error{fmt.Errorf("failed to search for Terraform files: %v", err)}
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return formatError("URL returned non-OK status:\n  %s\n  Status: %d", url, resp.StatusCode)
+	if len(stray) == 0 {
+		return nil
 	}
-
-	return nil
+	return []error{&ValidationError{
+		Check:    CheckStrayTerraform,
+		Severity: SeverityWarning,
+		Err: formatError("Terraform files outside the module layout are probably committed by mistake:\n  %s",
+			strings.Join(stray, "\n  ")),
+	}}
 }
 
 // TerraformDefinitionValidator validates Terraform definitions
 type TerraformDefinitionValidator struct {
-	data string
+	rootNode ast.Node
+	section  string
+	rootDir  string
+	strict   bool
 }
 
 // NewTerraformDefinitionValidator creates a new TerraformDefinitionValidator
-func NewTerraformDefinitionValidator(data string) *TerraformDefinitionValidator {
-	return &TerraformDefinitionValidator{data: data}
+func NewTerraformDefinitionValidator(rootNode ast.Node, section, rootDir string) *TerraformDefinitionValidator {
+	return &TerraformDefinitionValidator{rootNode: rootNode, section: section, rootDir: rootDir}
 }
 
 // Validate compares Terraform resources with those documented in the markdown
 func (tdv *TerraformDefinitionValidator) Validate() []error {
-	tfResources, tfDataSources, err := extractTerraformResources()
+	tfResources, tfDataSources, err := extractTerraformResources(tdv.rootDir)
 	if err != nil {
 		return []error{err}
 	}
 
-	readmeResources, readmeDataSources, err := extractReadmeResources(tdv.data)
+	readmeResources, readmeDataSources, err := extractReadmeResources(tdv.rootNode, tdv.section)
 	if err != nil {
 		return []error{err}
 	}
 
+	// Resources are compared by type, as loops and repeated blocks make names differ between code and docs
 	var errors []error
-	errors = append(errors, compareTerraformAndMarkdown(tfResources, readmeResources, "Resources")...)
-	errors = append(errors, compareTerraformAndMarkdown(tfDataSources, readmeDataSources, "Data Sources")...)
+	errors = append(errors, compareTerraformAndMarkdown(resourceTypes(tfResources), resourceTypes(readmeResources), "Resources", tdv.strict)...)
+	errors = append(errors, compareTerraformAndMarkdown(resourceTypes(tfDataSources), resourceTypes(readmeDataSources), "Data Sources", tdv.strict)...)
 
 	return errors
 }
 
 // ItemValidator validates items in Terraform and markdown
 type ItemValidator struct {
-	data      string
+	rootNode  ast.Node
 	itemType  string
 	blockType string
 	section   string
 	fileName  string
+	rootDir   string
 }
 
 // NewItemValidator creates a new ItemValidator
-func NewItemValidator(data, itemType, blockType, section, fileName string) *ItemValidator {
+func NewItemValidator(rootNode ast.Node, itemType, blockType, section, fileName, rootDir string) *ItemValidator {
 	return &ItemValidator{
-		data:      data,
+		rootNode:  rootNode,
 		itemType:  itemType,
 		blockType: blockType,
 		section:   section,
 		fileName:  fileName,
+		rootDir:   rootDir,
 	}
 }
 
 // Validate compares Terraform items with those documented in the markdown
 func (iv *ItemValidator) Validate() []error {
-	workspace := os.Getenv("GITHUB_WORKSPACE")
-	if workspace == "" {
-		var err error
-		workspace, err = os.Getwd()
-		if err != nil {
-			return []error{fmt.Errorf("failed to get current working directory: %v", err)}
-		}
-	}
-	filePath := filepath.Join(workspace, "caller", iv.fileName)
+	filePath := filepath.Join(iv.rootDir, iv.fileName)
 	tfItems, err := extractTerraformItems(filePath, iv.blockType)
 	if err != nil {
 		return []error{err}
 	}
 
-	mdItems, err := extractMarkdownSectionItems(iv.data, iv.section)
+	mdItems, err := extractMarkdownSectionItems(iv.rootNode, iv.section)
 	if err != nil {
 		return []error{err}
 	}
 
-	return compareTerraformAndMarkdown(tfItems, mdItems, iv.itemType)
+	return compareTerraformAndMarkdown(tfItems, mdItems, iv.itemType, false)
 }
 
 // Helper functions
 
+// parseMarkdown parses a markdown document into an AST
+func parseMarkdown(data string) ast.Node {
+	extensions := parser.CommonExtensions | parser.AutoHeadingIDs
+	p := parser.NewWithExtensions(extensions)
+	return markdown.Parse([]byte(data), p)
+}
+
 // compareHeaders compares expected and actual headers
 func compareHeaders(expected, actual string) error {
 	if expected != actual {
@@ -457,6 +2735,30 @@ func compareHeaders(expected, actual string) error {
 	return nil
 }
 
+// envList splits a comma separated environment variable into its non-empty entries
+func envList(name string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(name), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// envBool reports whether a boolean environment variable is set to true, an unset variable is false
+func envBool(name string) (bool, error) {
+	value := strings.TrimSpace(os.Getenv(name))
+	if value == "" {
+		return false, nil
+	}
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid value for %s: %q is not a boolean", name, value)
+	}
+	return enabled, nil
+}
+
 // formatError formats an error message
 func formatError(format string, args ...interface{}) error {
 	return fmt.Errorf(format, args...)
@@ -475,35 +2777,78 @@ func equalSlices(a, b []string) bool {
 	return true
 }
 
-// findMissingItems finds items in a that are not in b
-func findMissingItems(a, b []string) []string {
-	bSet := make(map[string]struct{}, len(b))
-	for _, x := range b {
-		bSet[x] = struct{}{}
+// countItems counts the occurrences of every item, keeping the order in which they first appear
+func countItems(items []string) ([]string, map[string]int) {
+	var order []string
+	counts := make(map[string]int, len(items))
+	for _, item := range items {
+		if counts[item] == 0 {
+			order = append(order, item)
+		}
+		counts[item]++
 	}
+	return order, counts
+}
+
+// findMissingItems finds items that do not occur in the given counts
+func findMissingItems(items []string, counts map[string]int) []string {
 	var missing []string
-	for _, x := range a {
-		if _, found := bSet[x]; !found {
-			missing = append(missing, x)
+	for _, item := range items {
+		if counts[item] == 0 {
+			missing = append(missing, item)
 		}
 	}
 	return missing
 }
 
-// compareTerraformAndMarkdown compares items in Terraform and markdown
-func compareTerraformAndMarkdown(tfItems, mdItems []string, itemType string) []error {
+// resourceTypes returns the type of every resource address, e.g. azurerm_subnet for azurerm_subnet.this
+func resourceTypes(names []string) []string {
+	types := make([]string, 0, len(names))
+	for _, name := range names {
+		resourceType, _, _ := strings.Cut(name, ".")
+		types = append(types, resourceType)
+	}
+	return types
+}
+
+// times describes how often an item occurs
+func times(n int) string {
+	if n == 1 {
+		return "once"
+	}
+	return fmt.Sprintf("%d times", n)
+}
+
+// compareTerraformAndMarkdown compares items in Terraform and markdown as sets, and in strict mode
+// also the number of times each item occurs on both sides
+func compareTerraformAndMarkdown(tfItems, mdItems []string, itemType string, strict bool) []error {
 	var errors []error
 
-	missingInMarkdown := findMissingItems(tfItems, mdItems)
+	tfOrder, tfCounts := countItems(tfItems)
+	mdOrder, mdCounts := countItems(mdItems)
+
+	missingInMarkdown := findMissingItems(tfOrder, mdCounts)
 	if len(missingInMarkdown) > 0 {
 		errors = append(errors, formatError("%s missing in markdown:\n  %s", itemType, strings.Join(missingInMarkdown, "\n  ")))
 	}
 
-	missingInTerraform := findMissingItems(mdItems, tfItems)
+	missingInTerraform := findMissingItems(mdOrder, tfCounts)
 	if len(missingInTerraform) > 0 {
 		errors = append(errors, formatError("%s in markdown but missing in Terraform:\n  %s", itemType, strings.Join(missingInTerraform, "\n  ")))
 	}
 
+	if strict {
+		var mismatches []string
+		for _, item := range tfOrder {
+			if md := mdCounts[item]; md > 0 && md != tfCounts[item] {
+				mismatches = append(mismatches, fmt.Sprintf("code defines %s %s, README lists it %s", item, times(tfCounts[item]), times(md)))
+			}
+		}
+		if len(mismatches) > 0 {
+			errors = append(errors, formatError("%s count differs between Terraform and markdown:\n  %s", itemType, strings.Join(mismatches, "\n  ")))
+		}
+	}
+
 	return errors
 }
 
@@ -565,19 +2910,15 @@ func filterUnsupportedBlockDiagnostics(diags hcl.Diagnostics) hcl.Diagnostics {
 }
 
 // extractMarkdownSectionItems extracts items from a markdown section
-func extractMarkdownSectionItems(data, sectionName string) ([]string, error) {
-	extensions := parser.CommonExtensions | parser.AutoHeadingIDs
-	p := parser.NewWithExtensions(extensions)
-	rootNode := markdown.Parse([]byte(data), p)
-
+func extractMarkdownSectionItems(rootNode ast.Node, sectionName string) ([]string, error) {
 	var items []string
-	var inTargetSection bool
+	var inTargetSection, seen bool
 
 	ast.WalkFunc(rootNode, func(node ast.Node, entering bool) ast.WalkStatus {
-		if heading, ok := node.(*ast.Heading); ok && entering && heading.Level == 2 {
+		if heading, ok := node.(*ast.Heading); ok && entering && heading.Level == sectionLevel {
 			text := strings.TrimSpace(extractText(heading))
-			if strings.EqualFold(text, sectionName) || strings.EqualFold(text, sectionName+"s") {
-				inTargetSection = true
+			if (strings.EqualFold(text, sectionName) || strings.EqualFold(text, sectionName+"s")) && !seen {
+				inTargetSection, seen = true, true
 				return ast.GoToNext
 			}
 			inTargetSection = false
@@ -625,21 +2966,17 @@ func extractMarkdownSectionItems(data, sectionName string) ([]string, error) {
 	return items, nil
 }
 
-// extractReadmeResources extracts resources and data sources from the markdown
-func extractReadmeResources(data string) ([]string, []string, error) {
-	extensions := parser.CommonExtensions | parser.AutoHeadingIDs
-	p := parser.NewWithExtensions(extensions)
-	rootNode := markdown.Parse([]byte(data), p)
-
+// extractReadmeResources extracts resources and data sources from the markdown section with the given name
+func extractReadmeResources(rootNode ast.Node, sectionName string) ([]string, []string, error) {
 	var resources []string
 	var dataSources []string
-	var inResourcesSection bool
+	var inResourcesSection, seen bool
 
 	ast.WalkFunc(rootNode, func(node ast.Node, entering bool) ast.WalkStatus {
-		if heading, ok := node.(*ast.Heading); ok && entering && heading.Level == 2 {
+		if heading, ok := node.(*ast.Heading); ok && entering && heading.Level == sectionLevel {
 			text := strings.TrimSpace(extractText(heading))
-			if strings.EqualFold(text, "Resources") {
-				inResourcesSection = true
+			if strings.EqualFold(text, sectionName) && !seen {
+				inResourcesSection, seen = true, true
 				return ast.GoToNext
 			}
 			inResourcesSection = false
@@ -721,22 +3058,12 @@ func extractTextFromNodes(nodes []ast.Node) string {
 	return sb.String()
 }
 
-// extractTerraformResources extracts resources and data sources from Terraform files
-func extractTerraformResources() ([]string, []string, error) {
+// extractTerraformResources extracts resources and data sources from the Terraform files under rootDir
+func extractTerraformResources(rootDir string) ([]string, []string, error) {
 	var resources []string
 	var dataSources []string
 
-	workspace := os.Getenv("GITHUB_WORKSPACE")
-	if workspace == "" {
-		var err error
-		workspace, err = os.Getwd()
-		if err != nil {
-			return nil, nil, fmt.Errorf("failed to get current working directory: %v", err)
-		}
-	}
-
-	callerPath := filepath.Join(workspace, "caller")
-	allResources, allDataSources, err := extractRecursively(callerPath)
+	allResources, allDataSources, err := extractRecursively(rootDir)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -858,12 +3185,121 @@ func TestMarkdown(t *testing.T) {
 		readmePath = envPath
 	}
 
-	validator, err := NewMarkdownValidator(readmePath)
+	// On pull requests only URLs on changed README lines are checked for liveness
+	var opts []Option
+	if os.Getenv("GITHUB_EVENT_NAME") == "pull_request" {
+		if base := os.Getenv("GITHUB_BASE_REF"); base != "" {
+			opts = append(opts, WithChangedLinesFromGit("origin/"+base))
+		}
+	}
+
+	// Layouts with Terraform files in other places, e.g. test fixtures, list them relative to the repository
+	if dirs := envList("TF_ALLOWED_DIRS"); len(dirs) > 0 {
+		opts = append(opts, WithAllowedTerraformDirs(dirs...))
+	}
+
+	// Examples that are not meant to be documented, e.g. work in progress, are skipped by name
+	if patterns := envList("SKIP_EXAMPLES"); len(patterns) > 0 {
+		opts = append(opts, WithSkipExamples(patterns...))
+	}
+
+	// Documentation conventions, e.g. a GOALS.md with a Non-Goals heading, are supplied by the caller.
+	// A relative rules file is taken from the repository root.
+	if files := envList("ADDITIONAL_FILES"); len(files) > 0 {
+		opts = append(opts, WithAdditionalFiles(files...))
+	}
+	if path := os.Getenv("CONTENT_RULES_FILE"); path != "" {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(filepath.Dir(readmePath), path)
+		}
+		rules, err := loadContentRules(path)
+		if err != nil {
+			t.Fatalf("Failed to load content rules: %v", err)
+		}
+		opts = append(opts, WithFileContentRules(rules))
+	}
+
+	// Translated READMEs are discovered next to the README unless listed explicitly, and are
+	// checked with the section names of their locale
+	localized := envList("LOCALIZED_READMES")
+	if len(localized) == 0 {
+		discovered, err := discoverLocalizedReadmes(readmePath)
+		if err != nil {
+			t.Fatalf("Failed to find localized READMEs: %v", err)
+		}
+		localized = discovered
+	}
+	if len(localized) > 0 {
+		opts = append(opts, WithLocalizedReadmes(localized...))
+	}
+	if path := os.Getenv("SECTION_NAMES_FILE"); path != "" {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(filepath.Dir(readmePath), path)
+		}
+		names, err := loadSectionNames(path)
+		if err != nil {
+			t.Fatalf("Failed to load section names: %v", err)
+		}
+		for locale, sections := range names {
+			opts = append(opts, WithSectionNames(locale, sections))
+		}
+	}
+
+	// Repositories with many links to rate limited sites can lower the number of parallel requests
+	if value := strings.TrimSpace(os.Getenv("URL_CHECK_CONCURRENCY")); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			t.Fatalf("Failed to read options: invalid value for URL_CHECK_CONCURRENCY: %q is not a number", value)
+		}
+		opts = append(opts, WithConcurrentURLChecks(n))
+	}
+
+	// Runners behind a TLS inspecting proxy trust its CA from a PEM file in the repository
+	if path := os.Getenv("TLS_CA_FILE"); path != "" {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(filepath.Dir(readmePath), path)
+		}
+		cfg, err := loadCACertificates(path)
+		if err != nil {
+			t.Fatalf("Failed to load CA certificates: %v", err)
+		}
+		opts = append(opts, WithTLSConfig(cfg))
+	}
+
+	// Optional checks and HTTP settings are turned on by the caller with boolean environment variables
+	for _, check := range []struct {
+		env string
+		opt Option
+	}{
+		{"HTTP_PROXY_FROM_ENVIRONMENT", WithProxyFromEnvironment()},
+		{"CHECK_VARIABLE_USAGE", WithVariableUsage()},
+		{"CHECK_STRICT_RESOURCE_COUNTS", WithStrictResourceCounts()},
+		{"CHECK_COLUMN_ORDER", WithColumnOrder()},
+		{"CHECK_EXAMPLE_DOCS", WithExampleDocs()},
+		{"CHECK_EXAMPLE_PROVIDERS", WithExampleProviders()},
+		{"CHECK_DYNAMIC_REFERENCES", WithDynamicReferences()},
+		{"CHECK_OUTPUT_DESCRIPTIONS", WithOutputDescriptions()},
+	} {
+		enabled, err := envBool(check.env)
+		if err != nil {
+			t.Fatalf("Failed to read options: %v", err)
+		}
+		if enabled {
+			opts = append(opts, check.opt)
+		}
+	}
+
+	validator, err := NewMarkdownValidator(readmePath, opts...)
 	if err != nil {
 		t.Fatalf("Failed to create validator: %v", err)
 	}
 
-	errors := validator.Validate()
+	errors, warnings := validator.ValidateWithSeverity()
+	checked, skipped, invalid := validator.URLCounts()
+	t.Logf("URLs checked: %d, skipped: %d, invalid: %d", checked, skipped, invalid)
+	for _, err := range warnings {
+		t.Logf("Validation warning: %v", err)
+	}
 	if len(errors) > 0 {
 		for _, err := range errors {
 			t.Errorf("Validation error: %v", err)
@@ -0,0 +1,6 @@
+data "azurerm_client_config" "current" {}
+
+resource "azurerm_resource_group" "rg" {
+  name     = var.name
+  location = var.location
+}
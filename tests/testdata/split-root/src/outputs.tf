@@ -0,0 +1,4 @@
+output "group" {
+  description = "resource group details"
+  value       = azurerm_resource_group.rg
+}
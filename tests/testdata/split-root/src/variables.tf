@@ -0,0 +1,9 @@
+variable "name" {
+  description = "name of the resource group"
+  type        = string
+}
+
+variable "location" {
+  description = "location of the resource group"
+  type        = string
+}
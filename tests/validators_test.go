@@ -0,0 +1,1765 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// countingTransport answers every request with 200 OK and counts the calls
+type countingTransport struct {
+	calls atomic.Int32
+}
+
+func (ct *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ct.calls.Add(1)
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader("")),
+		Request:    req,
+	}, nil
+}
+
+func TestValidateColumns(t *testing.T) {
+	required := []string{"Name", "Description", "Required"}
+	optional := []string{"Type", "Default"}
+
+	tests := []struct {
+		name     string
+		actual   []string
+		ordered  bool
+		issue    ColumnIssue
+		expected []string
+		message  string
+	}{
+		{
+			name:   "valid",
+			actual: []string{"Name", "Description", "Type", "Default", "Required"},
+		},
+		{
+			name:     "case mismatch",
+			actual:   []string{"name", "Description", "Required"},
+			issue:    ColumnCaseMismatch,
+			expected: []string{"Name", "Description", "Required"},
+			message:  "case mismatch: 'name' should be 'Name'",
+		},
+		{
+			name:     "missing column",
+			actual:   []string{"Name", "Description"},
+			issue:    ColumnMissing,
+			expected: []string{"Name", "Description", "Required"},
+			message:  "missing column: 'Required'",
+		},
+		{
+			name:     "extra column",
+			actual:   []string{"Name", "Description", "Sensitive", "Required"},
+			issue:    ColumnExtra,
+			expected: []string{"Name", "Description", "Required"},
+			message:  "extra column: 'Sensitive'",
+		},
+		{
+			name:     "duplicate column",
+			actual:   []string{"Name", "Name", "Description", "Required"},
+			issue:    ColumnDuplicate,
+			expected: []string{"Name", "Description", "Required"},
+			message:  "duplicate column: 'Name'",
+		},
+		{
+			name:   "order not enforced",
+			actual: []string{"Description", "Name", "Required"},
+		},
+		{
+			name:     "swapped order",
+			actual:   []string{"Description", "Name", "Required"},
+			ordered:  true,
+			issue:    ColumnSwapped,
+			expected: []string{"Name", "Description", "Required"},
+			message:  "swapped order: 'Description' found where 'Name' was expected",
+		},
+		{
+			name:     "mixed",
+			actual:   []string{"name", "Description"},
+			issue:    ColumnMixed,
+			expected: []string{"Name", "Description", "Required"},
+			message:  "missing column: 'Required'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := validateColumns("Inputs", required, optional, tt.actual, tt.ordered)
+			if tt.issue == "" {
+				if len(errs) != 0 {
+					t.Fatalf("expected no errors, got %v", errs)
+				}
+				return
+			}
+
+			if len(errs) != 1 {
+				t.Fatalf("expected 1 error, got %d: %v", len(errs), errs)
+			}
+
+			var colErr *ColumnError
+			if !errors.As(errs[0], &colErr) {
+				t.Fatalf("expected *ColumnError, got %T", errs[0])
+			}
+			if colErr.Issue != tt.issue {
+				t.Errorf("issue = %q, want %q", colErr.Issue, tt.issue)
+			}
+			if strings.Join(colErr.Expected, ",") != strings.Join(tt.expected, ",") {
+				t.Errorf("expected row = %v, want %v", colErr.Expected, tt.expected)
+			}
+			if !strings.Contains(colErr.Error(), tt.message) {
+				t.Errorf("error %q does not contain %q", colErr.Error(), tt.message)
+			}
+		})
+	}
+}
+
+func TestRenderColumnRows(t *testing.T) {
+	expected, actual, caret := renderColumnRows(
+		[]string{"Name", "Description", "Required"},
+		[]string{"Name", "description", "Required"},
+	)
+
+	if want := "| Name | Description | Required |"; expected != want {
+		t.Errorf("expected row = %q, want %q", expected, want)
+	}
+	if want := "| Name | description | Required |"; actual != want {
+		t.Errorf("actual row = %q, want %q", actual, want)
+	}
+	if want := strings.Repeat(" ", 9) + "^"; caret != want {
+		t.Errorf("caret = %q, want %q", caret, want)
+	}
+}
+
+func TestExampleProvidersValidator(t *testing.T) {
+	dir := t.TempDir()
+	examples := map[string]string{
+		"default": `provider "azurerm" {
+  features {}
+}
+
+module "rg" {
+  source = "../../"
+}
+`,
+		"no-features": `provider "azurerm" {}
+
+resource "azurerm_resource_group" "rg" {
+  name     = "rg"
+  location = "westeurope"
+}
+`,
+		"aliases": `provider "azurerm" {
+  features {}
+}
+
+provider "azurerm" {
+  alias = "secondary"
+  features {}
+}
+
+provider "azurerm" {
+  alias = "unused"
+  features {}
+}
+
+provider "random" {}
+
+module "peering" {
+  source = "../../"
+
+  providers = {
+    azurerm      = azurerm
+    azurerm.peer = azurerm.missing
+  }
+}
+
+resource "azurerm_resource_group" "peer" {
+  provider = azurerm.secondary
+  name     = "peer"
+  location = "westeurope"
+}
+`,
+		"legacy": `provider "azurerm" {}
+`,
+	}
+	for name, content := range examples {
+		if err := os.MkdirAll(filepath.Join(dir, name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name, "main.tf"), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	errs := NewExampleProvidersValidator(dir, []string{"leg*"}).Validate()
+	want := []string{
+		"example providers:\n  aliases\n  main.tf: provider 'azurerm.missing' is used but not declared",
+		"example providers:\n  aliases\n  main.tf: provider 'azurerm.unused' is declared but never used",
+		"example providers:\n  aliases\n  main.tf: provider 'random' is declared but never used",
+		"example providers:\n  no-features\n  main.tf: provider 'azurerm' has no features block",
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("errors = %v, want %q", errs, want)
+	}
+	for i, err := range errs {
+		if err.Error() != want[i] {
+			t.Errorf("error %d = %q, want %q", i, err.Error(), want[i])
+		}
+	}
+}
+
+// writeModuleFixture writes a root module with unused, undeclared and misspelled references and
+// undocumented outputs, plus a clean submodule, shared by the checks built on the module index
+func writeModuleFixture(t *testing.T) string {
+	t.Helper()
+
+	root := t.TempDir()
+	files := map[string]string{
+		"variables.tf": `variable "name" {
+  description = "name of the resource group"
+}
+
+variable "location" {
+  description = "location of the resource group"
+  default     = "westeurope"
+}
+
+variable "legacy" {}
+
+variable "unused_with_default" {
+  description = "kept for compatibility"
+  default     = null
+}
+
+variable "sku" {
+  description = "sku of the resource group"
+  default     = "standard"
+
+  validation {
+    condition     = contains(["standard", "premium"], var.sku)
+    error_message = "sku must be standard or premium"
+  }
+}
+
+variable "ip_restrictions" {
+  description = "ip restrictions of the app"
+  default     = []
+}
+`,
+		"main.tf": `locals {
+  site_config = {}
+}
+
+resource "azurerm_resource_group" "rg" {
+  name     = var.name
+  location = coalesce(var.location, "westeurope")
+  tags     = { for k, v in var.tags : k => v }
+}
+
+resource "azurerm_linux_web_app" "app" {
+  dynamic "ip_restriction" {
+    for_each = try(var.ip_restricitons, [])
+
+    content {
+      name = ip_restriction.value.name
+
+      dynamic "headers" {
+        for_each = ip_restriction.value.headers
+        content {}
+      }
+    }
+  }
+
+  dynamic "site_config" {
+    for_each = local.site_configs
+    iterator = cfg
+
+    content {
+      dynamic "application_stack" {
+        for_each = cfg.value.stacks
+        content {}
+      }
+    }
+  }
+
+  dynamic "identity" {
+    for_each = azurerm_resource_group.rg.id != "" ? [1] : []
+    content {}
+  }
+}
+`,
+		"outputs.tf": `output "group" {
+  value = "${var.name}-${var.suffix}"
+}
+
+output "id" {
+  description = "id of the resource group"
+  value       = azurerm_resource_group.rg.id
+}
+
+output "location" {
+  description = "  "
+  value       = azurerm_resource_group.rg.location
+}
+`,
+		"modules/network/variables.tf": `variable "address_space" {
+  description = "address space of the network"
+}
+
+variable "subnets" {
+  description = "subnets of the network"
+}
+`,
+		"modules/network/main.tf": `resource "azurerm_virtual_network" "vnet" {
+  address_space = var.address_space
+}
+
+resource "azurerm_subnet" "subnet" {
+  dynamic "delegation" {
+    for_each = { for k, v in var.subnets : k => v if v.delegate }
+    content {}
+  }
+}
+`,
+	}
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+// assertErrors checks that the errors have exactly the given messages
+func assertErrors(t *testing.T, errs []error, want []string) {
+	t.Helper()
+	if len(errs) != len(want) {
+		t.Fatalf("errors = %v, want %q", errs, want)
+	}
+	for i, err := range errs {
+		if err.Error() != want[i] {
+			t.Errorf("error %d = %q, want %q", i, err.Error(), want[i])
+		}
+	}
+}
+
+func TestVariableUsageValidator(t *testing.T) {
+	root := writeModuleFixture(t)
+
+	errs := NewVariableUsageValidator(root).Validate()
+	assertErrors(t, errs, []string{
+		"variables declared but never referenced in root:\n  legacy (required, no description)\n  unused_with_default\n  sku\n  ip_restrictions",
+		"variables referenced but not declared in root:\n  tags\n  ip_restricitons\n  suffix",
+	})
+
+	// Unused variables only warn, undeclared ones fail the run
+	for i, want := range []Severity{SeverityWarning, SeverityError} {
+		var verr *ValidationError
+		if !errors.As(errs[i], &verr) || verr.Check != CheckVariableUsage || verr.Severity != want {
+			t.Errorf("error %d = %#v, want a %s from the %s check", i, errs[i], want, CheckVariableUsage)
+		}
+	}
+
+	index, err := indexModule(root, filepath.Join(root, "modules", "network"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs := validateVariableUsage(index); len(errs) != 0 {
+		t.Errorf("expected the submodule to be clean, got %v", errs)
+	}
+}
+
+func TestEnvBool(t *testing.T) {
+	for value, want := range map[string]bool{"": false, "true": true, " TRUE ": true, "false": false, "1": true} {
+		t.Setenv("CHECK_VARIABLE_USAGE", value)
+		if got, err := envBool("CHECK_VARIABLE_USAGE"); err != nil || got != want {
+			t.Errorf("envBool(%q) = %v, %v, want %v", value, got, err, want)
+		}
+	}
+
+	t.Setenv("CHECK_VARIABLE_USAGE", "yes")
+	if _, err := envBool("CHECK_VARIABLE_USAGE"); err == nil || !strings.Contains(err.Error(), `invalid value for CHECK_VARIABLE_USAGE: "yes"`) {
+		t.Errorf("expected invalid value error, got %v", err)
+	}
+}
+
+func TestOutputDescriptionValidator(t *testing.T) {
+	root := writeModuleFixture(t)
+
+	errs := NewOutputDescriptionValidator(root).Validate()
+	assertErrors(t, errs, []string{
+		"output without a description in root:\n  outputs.tf:1: group",
+		"output without a description in root:\n  outputs.tf:10: location",
+	})
+	for i, err := range errs {
+		var verr *ValidationError
+		if !errors.As(err, &verr) || verr.Check != CheckOutputDescriptions || verr.Severity != SeverityWarning {
+			t.Errorf("error %d = %#v, want a warning from the %s check", i, err, CheckOutputDescriptions)
+		}
+	}
+}
+
+func TestDynamicReferenceValidator(t *testing.T) {
+	root := writeModuleFixture(t)
+
+	// Iterators of enclosing dynamic blocks, resources and the clean submodule are not reported
+	assertErrors(t, NewDynamicReferenceValidator(root).Validate(), []string{
+		"unresolved dynamic block reference in root:\n  main.tf:13: var.ip_restricitons in dynamic \"ip_restriction\" for_each is not declared, did you mean var.ip_restrictions?",
+		"unresolved dynamic block reference in root:\n  main.tf:26: local.site_configs in dynamic \"site_config\" for_each is not declared, did you mean local.site_config?",
+	})
+
+	if got := nearestDeclaration("var.completely_different", map[string]bool{"var.name": true}); got != "" {
+		t.Errorf("expected no suggestion for a distant name, got %q", got)
+	}
+}
+
+func TestURLValidatorUsesInjectedClient(t *testing.T) {
+	transport := &countingTransport{}
+	client := &http.Client{Transport: transport}
+
+	data := "See https://example.com/docs and https://example.org/guide."
+	if errs := NewURLValidator(data, client).Validate(); len(errs) != 0 {
+		t.Fatalf("expected no errors, got %v", errs)
+	}
+	if got := transport.calls.Load(); got != 2 {
+		t.Errorf("round trips = %d, want 2", got)
+	}
+}
+
+func TestMarkdownValidatorHTTPClient(t *testing.T) {
+	t.Setenv("README_PATH", "")
+
+	readme := filepath.Join(t.TempDir(), "README.md")
+	if err := os.WriteFile(readme, []byte("Docs at https://example.com/docs.\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	transport := &countingTransport{}
+	mv, err := NewMarkdownValidator(readme, WithHTTPClient(&http.Client{Transport: transport}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range mv.validators {
+		if uv, ok := v.(*URLValidator); ok {
+			uv.Validate()
+		}
+	}
+	if got := transport.calls.Load(); got != 1 {
+		t.Errorf("round trips = %d, want 1", got)
+	}
+
+	// The transport conveniences can't be applied to custom round trippers and are not silently dropped
+	for _, opt := range []Option{WithProxyFromEnvironment(), WithTLSConfig(&tls.Config{})} {
+		if _, err := NewMarkdownValidator(readme, WithHTTPClient(&http.Client{Transport: transport}), opt); err == nil {
+			t.Error("expected an error combining a custom round tripper with a transport option")
+		}
+	}
+
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	mv, err = NewMarkdownValidator(readme, WithTLSConfig(cfg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := mv.client()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt, ok := client.Transport.(*http.Transport)
+	if !ok || rt.TLSClientConfig != cfg {
+		t.Error("expected TLS config to be applied to the default transport")
+	}
+
+	// An injected transport keeps its own settings and gets the conveniences on a copy
+	injected := &http.Transport{MaxIdleConns: 3}
+	mv, err = NewMarkdownValidator(readme, WithHTTPClient(&http.Client{Transport: injected}), WithProxyFromEnvironment(), WithTLSConfig(cfg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client, err = mv.client(); err != nil {
+		t.Fatal(err)
+	}
+	rt, ok = client.Transport.(*http.Transport)
+	if !ok || rt.MaxIdleConns != 3 || rt.TLSClientConfig != cfg || rt.Proxy == nil || injected.Proxy != nil {
+		t.Error("expected TLS and proxy options to be applied to a copy of the injected transport")
+	}
+	if defaultHTTPClient.Transport != nil {
+		t.Error("expected shared default client to be left unmodified")
+	}
+}
+
+func TestLoadCACertificates(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	bundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(path, bundle, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := loadCACertificates(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: cfg}}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("expected the loaded CA to be trusted, got %v", err)
+	}
+	resp.Body.Close()
+
+	if err := os.WriteFile(path, []byte("not a certificate"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadCACertificates(path); err == nil || !strings.Contains(err.Error(), "no PEM certificates found in ca.pem") {
+		t.Errorf("expected missing certificates error, got %v", err)
+	}
+}
+
+func TestMarkdownValidatorSplitRoots(t *testing.T) {
+	t.Setenv("README_PATH", "")
+	t.Setenv("GITHUB_WORKSPACE", t.TempDir())
+
+	fixture := filepath.Join("testdata", "split-root")
+	client := &http.Client{Transport: &countingTransport{}}
+
+	// With the default roots the Terraform files are looked up in the empty workspace
+	mv, err := NewMarkdownValidator(filepath.Join(fixture, "README.md"), WithHTTPClient(client))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs := mv.Validate(); len(errs) == 0 {
+		t.Fatal("expected errors when the terraform root is not configured")
+	}
+
+	mv, err = NewMarkdownValidator(
+		filepath.Join(fixture, "README.md"),
+		WithHTTPClient(client),
+		WithTerraformRoot(filepath.Join(fixture, "src")),
+		WithDocsRoot(fixture),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, err := range mv.Validate() {
+		t.Errorf("unexpected validation error: %v", err)
+	}
+}
+
+func TestParseUnifiedDiff(t *testing.T) {
+	diff := `diff --git a/README.md b/README.md
+index 1111111..2222222 100644
+--- a/README.md
++++ b/README.md
+@@ -3 +3 @@ intro
+-old
++new
+@@ -10,0 +11,3 @@ section
++one
++two
++three
+@@ -20,2 +22,0 @@ removed
+-gone
+-gone
+@@ -30,3 +30,4 @@ context
+ keep
+-old
++new
++++ b/README.md
+ keep
+diff --git a/docs/README.md b/docs/README.md
+--- a/docs/README.md
++++ b/docs/README.md
+@@ -1,0 +1,5 @@
++other file
+`
+
+	ranges, err := parseUnifiedDiff(strings.NewReader(diff), "/work/caller/README.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []LineRange{{Start: 3, End: 3}, {Start: 11, End: 13}, {Start: 31, End: 32}}
+	if len(ranges) != len(want) {
+		t.Fatalf("ranges = %v, want %v", ranges, want)
+	}
+	for i := range want {
+		if ranges[i] != want[i] {
+			t.Errorf("range %d = %v, want %v", i, ranges[i], want[i])
+		}
+	}
+
+	for line, expected := range map[int]bool{2: false, 3: true, 10: false, 11: true, 13: true, 14: false, 22: false, 30: false, 31: true, 32: true, 33: false} {
+		if got := linesContain(ranges, line); got != expected {
+			t.Errorf("linesContain(%d) = %v, want %v", line, got, expected)
+		}
+	}
+
+	if _, err := parseUnifiedDiff(strings.NewReader("+++ b/README.md\n@@ bogus @@\n"), "README.md"); err == nil {
+		t.Error("expected error for malformed hunk header")
+	}
+}
+
+func TestURLValidatorChangedLines(t *testing.T) {
+	data := "line one https://example.com/unchanged\nline two https://example.com/changed\nline three https://example.com/also-unchanged\n"
+
+	transport := &countingTransport{}
+	uv := NewURLValidator(data, &http.Client{Transport: transport})
+	uv.RestrictToLines([]LineRange{{Start: 2, End: 2}})
+
+	if errs := uv.Validate(); len(errs) != 0 {
+		t.Fatalf("expected no errors, got %v", errs)
+	}
+	if got := transport.calls.Load(); got != 1 {
+		t.Errorf("round trips = %d, want 1", got)
+	}
+
+	// Nothing changed means no liveness checks at all
+	transport = &countingTransport{}
+	uv = NewURLValidator(data, &http.Client{Transport: transport})
+	uv.RestrictToLines(nil)
+	uv.Validate()
+	if got := transport.calls.Load(); got != 0 {
+		t.Errorf("round trips = %d, want 0", got)
+	}
+}
+
+func TestValidateURLSyntax(t *testing.T) {
+	if err := validateURLSyntax("https://example.com/docs"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	for _, bad := range []string{"https://exa%zzmple.com", "ftp://example.com", "https:///path"} {
+		if err := validateURLSyntax(bad); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestMarkdownValidatorChangedLinesFromGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	t.Setenv("README_PATH", "")
+
+	dir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+
+	readme := filepath.Join(dir, "README.md")
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(readme, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("# Title\n\nhttps://example.com/a\n\nhttps://example.com/b\n")
+	git("init", "-q")
+	git("add", "README.md")
+	git("commit", "-q", "-m", "initial")
+	git("branch", "base")
+	write("# Title\n\nhttps://example.com/a\n\nhttps://example.com/c\n")
+	git("commit", "-q", "-am", "change")
+
+	// Changes on the base branch after the branch point are not part of the pull request
+	git("checkout", "-q", "base")
+	write("# Renamed\n\nhttps://example.com/a\n\nhttps://example.com/b\n")
+	git("commit", "-q", "-am", "rename")
+	git("checkout", "-q", "-")
+
+	mv, err := NewMarkdownValidator(readme, WithChangedLinesFromGit("base"), WithTerraformRoot(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var uv *URLValidator
+	for _, v := range mv.validators {
+		if u, ok := v.(*URLValidator); ok {
+			uv = u
+		}
+	}
+	if want := []LineRange{{Start: 5, End: 5}}; len(uv.changedLines) != 1 || uv.changedLines[0] != want[0] {
+		t.Errorf("changed lines = %v, want %v", uv.changedLines, want)
+	}
+
+	// An unknown base ref falls back to checking every URL instead of failing construction
+	transport := &countingTransport{}
+	mv, err = NewMarkdownValidator(readme, WithChangedLinesFromGit("origin/missing"), WithTerraformRoot(dir),
+		WithHTTPClient(&http.Client{Transport: transport}))
+	if err != nil {
+		t.Fatalf("expected the validator to fall back to full URL checks, got %v", err)
+	}
+	errs, warnings := mv.ValidateWithSeverity()
+	if len(errs) == 0 {
+		t.Error("expected the structural checks to still run")
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0].Error(), "changed lines unavailable") {
+		t.Errorf("warnings = %v, want a changed lines warning", warnings)
+	}
+	if got := transport.calls.Load(); got != 2 {
+		t.Errorf("round trips = %d, want 2", got)
+	}
+}
+
+// largeReadme embeds a generated JSON block of roughly the given size into the fixture README
+func largeReadme(tb testing.TB, size int) (string, string) {
+	tb.Helper()
+
+	content, err := os.ReadFile(filepath.Join("testdata", "split-root", "README.md"))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	readme := string(content)
+
+	var block strings.Builder
+	block.WriteString("```json\n[\n")
+	for i := 0; block.Len() < size; i++ {
+		if i%1000 == 0 {
+			block.WriteString(`  {"name": "example", "url": "https://example.com/item", "tags": ["a", "b"]},` + "\n")
+			continue
+		}
+		block.WriteString(`  {"name": "example", "id": 12345, "tags": ["a", "b", "c"]},` + "\n")
+	}
+	block.WriteString("]\n```\n\n")
+
+	large := strings.Replace(readme, "## Notes\n\n", "## Notes\n\n"+block.String(), 1)
+	return readme, large
+}
+
+// markdownErrors runs the validators that only depend on the README content
+func markdownErrors(rootNode ast.Node) []string {
+	errs := NewSectionValidator(rootNode, nil).Validate()
+	if _, err := extractMarkdownSectionItems(rootNode, "Inputs"); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := extractMarkdownSectionItems(rootNode, "Outputs"); err != nil {
+		errs = append(errs, err)
+	}
+	if _, _, err := extractReadmeResources(rootNode, "Resources"); err != nil {
+		errs = append(errs, err)
+	}
+
+	var messages []string
+	for _, err := range errs {
+		messages = append(messages, err.Error())
+	}
+	return messages
+}
+
+func TestLargeReadmeProducesSameErrors(t *testing.T) {
+	readme, large := largeReadme(t, 256<<10)
+
+	// Break a table so both documents have something to report
+	readme = strings.Replace(readme, "| Name | Version |", "| name | Version |", 1)
+	large = strings.Replace(large, "| Name | Version |", "| name | Version |", 1)
+
+	want := markdownErrors(parseMarkdown(readme))
+	got := markdownErrors(parseMarkdown(large))
+	if len(want) == 0 {
+		t.Fatal("expected the broken table to be reported")
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("errors differ for large README:\n got: %v\nwant: %v", got, want)
+	}
+
+	large += "mail mailto:someone@example.com and urn-like text: nothing\nlast https://example.org/end"
+	whole, byLine := extractURLs(large), extractURLsByLine(large)
+	if len(whole) == 0 || len(whole) != len(byLine) {
+		t.Fatalf("extracted %d URLs by line, want %d", len(byLine), len(whole))
+	}
+	for i := range whole {
+		if whole[i] != byLine[i] {
+			t.Errorf("match %d = %+v by line, want %+v", i, byLine[i], whole[i])
+		}
+	}
+}
+
+func BenchmarkLargeReadme(b *testing.B) {
+	_, large := largeReadme(b, 2<<20)
+
+	b.Run("shared-ast", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			markdownErrors(parseMarkdown(large))
+		}
+	})
+
+	// Mirrors the previous behaviour where every validator parsed the document itself
+	b.Run("parse-per-validator", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			NewSectionValidator(parseMarkdown(large), nil).Validate()
+			extractMarkdownSectionItems(parseMarkdown(large), "Inputs")
+			extractMarkdownSectionItems(parseMarkdown(large), "Outputs")
+			extractReadmeResources(parseMarkdown(large), "Resources")
+		}
+	})
+
+	b.Run("url-extraction", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			extractURLs(large)
+		}
+	})
+
+	b.Run("url-extraction-by-line", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			extractURLsByLine(large)
+		}
+	})
+}
+
+// dutchSections maps the section names used by the Dutch fixture README
+var dutchSections = map[string]string{
+	"Goals":        "Doelen",
+	"Non-Goals":    "Niet-doelen",
+	"Resources":    "Bronnen",
+	"Requirements": "Vereisten",
+	"Inputs":       "Invoerparameters",
+	"Outputs":      "Uitvoer",
+	"Features":     "Functies",
+	"Testing":      "Testen",
+	"Authors":      "Auteurs",
+	"License":      "Licentie",
+	"Notes":        "Notities",
+	"Contributing": "Bijdragen",
+	"Reference":    "Referenties",
+}
+
+func TestMarkdownValidatorLocalizedReadmes(t *testing.T) {
+	t.Setenv("README_PATH", "")
+
+	fixture := filepath.Join("testdata", "split-root")
+	opts := []Option{
+		WithHTTPClient(&http.Client{Transport: &countingTransport{}}),
+		WithTerraformRoot(filepath.Join(fixture, "src")),
+		WithLocalizedReadmes("README.nl.md"),
+	}
+
+	mv, err := NewMarkdownValidator(filepath.Join(fixture, "README.md"), append(opts, WithSectionNames("nl", dutchSections))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, err := range mv.Validate() {
+		t.Errorf("unexpected validation error: %v", err)
+	}
+
+	// Without the mapping the English section names are looked up in the Dutch README
+	mv, err = NewMarkdownValidator(filepath.Join(fixture, "README.md"), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	errs := mv.Validate()
+	if len(errs) == 0 {
+		t.Fatal("expected errors for unmapped section names")
+	}
+	for _, err := range errs {
+		if !strings.HasPrefix(err.Error(), "[nl] ") {
+			t.Errorf("expected error to be prefixed with the locale: %v", err)
+		}
+	}
+}
+
+func TestReadmeLocale(t *testing.T) {
+	for path, want := range map[string]string{
+		"README.nl.md":         "nl",
+		"/repo/docs/README.de": "de",
+		"README.pt-BR.md":      "pt-BR",
+	} {
+		if got := readmeLocale(path); got != want {
+			t.Errorf("readmeLocale(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestDiscoverLocalizedReadmes(t *testing.T) {
+	fixture := filepath.Join("testdata", "split-root")
+
+	readmes, err := discoverLocalizedReadmes(filepath.Join(fixture, "README.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(fixture, "README.nl.md"); len(readmes) != 1 || readmes[0] != want {
+		t.Errorf("localized READMEs = %v, want [%s]", readmes, want)
+	}
+
+	if readmes, err := discoverLocalizedReadmes(filepath.Join(fixture, "src", "README.md")); err != nil || len(readmes) != 0 {
+		t.Errorf("expected no localized READMEs, got %v, %v", readmes, err)
+	}
+}
+
+func TestLoadSectionNames(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sections.json")
+	if err := os.WriteFile(path, []byte(`{"nl": {"Inputs": "Invoerparameters", "Outputs": "Uitvoer"}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	names, err := loadSectionNames(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sectionName(names["nl"], "Inputs"); got != "Invoerparameters" {
+		t.Errorf("Inputs = %q, want Invoerparameters", got)
+	}
+	if got := sectionName(names["nl"], "Resources"); got != "Resources" {
+		t.Errorf("unmapped section = %q, want Resources", got)
+	}
+
+	if err := os.WriteFile(path, []byte(`["nl"]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadSectionNames(path); err == nil || !strings.Contains(err.Error(), "failed to parse section names in sections.json") {
+		t.Errorf("expected parse error, got %v", err)
+	}
+}
+
+func TestURLCacheChecksOnce(t *testing.T) {
+	transport := &countingTransport{}
+	client := &http.Client{Transport: transport}
+	cache := newURLCache()
+
+	for _, data := range []string{"https://example.com/shared", "zie https://example.com/shared"} {
+		uv := NewURLValidator(data, client)
+		uv.cache = cache
+		uv.Validate()
+	}
+	if got := transport.calls.Load(); got != 1 {
+		t.Errorf("round trips = %d, want 1", got)
+	}
+}
+
+func TestURLCounts(t *testing.T) {
+	client := &http.Client{Transport: &countingTransport{}}
+	cache := newURLCache()
+
+	primary := NewURLValidator("https://example.com/shared https://example.com/changed ftp://example.com/files", client)
+	localized := NewURLValidator("https://example.com/shared https://example.com/changed ftp://example.com/files", client)
+	localized.RestrictToLines(nil)
+
+	mv := &MarkdownValidator{validators: []Validator{primary, localizedValidator{locale: "nl", Validator: localized}}}
+	for _, uv := range []*URLValidator{primary, localized} {
+		uv.cache = cache
+		uv.Validate()
+	}
+
+	// URLs shared between READMEs count once, and skipping a URL checked elsewhere doesn't count
+	if checked, skipped, invalid := mv.URLCounts(); checked != 2 || skipped != 0 || invalid != 1 {
+		t.Errorf("counts = %d, %d, %d, want 2 checked, 0 skipped and 1 invalid", checked, skipped, invalid)
+	}
+
+	primary.RestrictToLines(nil)
+	primary.Validate()
+	if checked, skipped, invalid := mv.URLCounts(); checked != 0 || skipped != 2 || invalid != 1 {
+		t.Errorf("counts = %d, %d, %d, want 0 checked, 2 skipped and 1 invalid", checked, skipped, invalid)
+	}
+}
+
+func TestFileContentRules(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("GOALS.md", "# Goals\n\nShip it.\n\n## Non-goals\n\n```md\n## Non-Goals\n```\n")
+	write("TESTING.md", "# Testing\n\n```bash\nmake test\n```\n\nRun `make test` before opening a PR.\n")
+
+	tests := []struct {
+		name    string
+		file    string
+		rules   []ContentRule
+		message []string
+	}{
+		{
+			name:  "heading present",
+			file:  "GOALS.md",
+			rules: []ContentRule{MustContainHeading("Goals")},
+		},
+		{
+			name:    "heading case mismatch reports line",
+			file:    "GOALS.md",
+			rules:   []ContentRule{MustContainHeading("Non-Goals")},
+			message: []string{"GOALS.md:5: missing heading 'Non-Goals', found 'Non-goals' instead"},
+		},
+		{
+			name:    "heading missing",
+			file:    "GOALS.md",
+			rules:   []ContentRule{MustContainHeading("Scope")},
+			message: []string{"GOALS.md:1: missing heading 'Scope'"},
+		},
+		{
+			name:  "fenced block present",
+			file:  "TESTING.md",
+			rules: []ContentRule{MustContainFencedBlock("bash")},
+		},
+		{
+			name:    "fenced block of other language reports line",
+			file:    "TESTING.md",
+			rules:   []ContentRule{MustContainFencedBlock("shell")},
+			message: []string{"TESTING.md:3: missing fenced block of language 'shell', found: bash (line 3)"},
+		},
+		{
+			name:  "pattern matches",
+			file:  "TESTING.md",
+			rules: []ContentRule{MustMatch("^Run `make test`")},
+		},
+		{
+			name:    "pattern does not match",
+			file:    "TESTING.md",
+			rules:   []ContentRule{MustMatch("^go test")},
+			message: []string{"TESTING.md:1: content does not match pattern '^go test'"},
+		},
+		{
+			name:    "multiple rules",
+			file:    "TESTING.md",
+			rules:   []ContentRule{MustContainHeading("Testing"), MustContainFencedBlock("sh"), MustMatch("terraform")},
+			message: []string{"TESTING.md:3: missing fenced block of language 'sh'", "TESTING.md:1: content does not match pattern 'terraform'"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fv := NewFileValidator(filepath.Join(dir, "README.md"), dir, dir, "GOALS.md")
+			if err := fv.addContentRules(tt.file, tt.rules, dir); err != nil {
+				t.Fatal(err)
+			}
+
+			errs := validateFileContent(filepath.Join(dir, tt.file), fv.rules[filepath.Join(dir, tt.file)])
+			if len(errs) != len(tt.message) {
+				t.Fatalf("expected %d errors, got %d: %v", len(tt.message), len(errs), errs)
+			}
+			for i, msg := range tt.message {
+				if !strings.Contains(errs[i].Error(), msg) {
+					t.Errorf("error %q does not contain %q", errs[i], msg)
+				}
+			}
+		})
+	}
+}
+
+func TestFileContentRulesConstruction(t *testing.T) {
+	t.Setenv("README_PATH", "")
+
+	fixture := filepath.Join("testdata", "split-root")
+	readme := filepath.Join(fixture, "README.md")
+	root := WithTerraformRoot(filepath.Join(fixture, "src"))
+
+	if _, err := NewMarkdownValidator(readme, root, WithFileContentRules(map[string][]ContentRule{
+		"GOALS.md": {MustContainHeading("Non-Goals")},
+	})); err == nil || !strings.Contains(err.Error(), "unknown file: GOALS.md") {
+		t.Errorf("expected unknown file error, got %v", err)
+	}
+
+	if _, err := NewMarkdownValidator(readme, root, WithFileContentRules(map[string][]ContentRule{
+		"TESTING.md": {MustMatch("(unclosed")},
+	})); err == nil || !strings.Contains(err.Error(), "does not compile: error parsing regexp: missing closing )") {
+		t.Errorf("expected invalid pattern error with its cause, got %v", err)
+	}
+
+	if _, err := NewMarkdownValidator(readme, root, WithFileContentRules(map[string][]ContentRule{
+		"TESTING.md": {{Kind: "section", Value: "Usage"}},
+	})); err == nil || !strings.Contains(err.Error(), `unknown kind "section"`) {
+		t.Errorf("expected unknown kind error, got %v", err)
+	}
+
+	mv, err := NewMarkdownValidator(readme, root,
+		WithHTTPClient(&http.Client{Transport: &countingTransport{}}),
+		WithFileContentRules(map[string][]ContentRule{
+			"TESTING.md":   {MustContainFencedBlock("shell")},
+			"variables.tf": {MustMatch(`^variable "name"`), {Kind: RulePattern, Value: `^variable "location"`}},
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errs := mv.Validate()
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "missing fenced block of language 'shell'") {
+		t.Errorf("expected only the TESTING.md rule to fail, got %v", errs)
+	}
+}
+
+func TestLoadContentRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	content := `{
+  "GOALS.md": [{"kind": "heading", "value": "Non-Goals"}],
+  "TESTING.md": [{"kind": "fenced block", "value": "shell"}, {"kind": "pattern", "value": "^## "}]
+}`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	rules, err := loadContentRules(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rules["GOALS.md"]; len(got) != 1 || got[0] != MustContainHeading("Non-Goals") {
+		t.Errorf("GOALS.md rules = %v", got)
+	}
+	if got := rules["TESTING.md"]; len(got) != 2 || got[0] != MustContainFencedBlock("shell") || got[1].Kind != RulePattern || got[1].Value != "^## " {
+		t.Errorf("TESTING.md rules = %v", got)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"GOALS.md": "Non-Goals"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadContentRules(path); err == nil || !strings.Contains(err.Error(), "failed to parse content rules in rules.json") {
+		t.Errorf("expected parse error, got %v", err)
+	}
+}
+
+func TestDuplicateSectionValidator(t *testing.T) {
+	data := strings.Join([]string{
+		"# Module",                          // 1
+		"",                                  // 2
+		"## Inputs",                         // 3
+		"",                                  // 4
+		"| Name | Description | Required |", // 5
+		"| --- | --- | --- |",               // 6
+		"| `name` | name | yes |",           // 7
+		"",                                  // 8
+		"## Usage",                          // 9
+		"",                                  // 10
+		"```md",                             // 11
+		"## Usage",                          // 12
+		"```",                               // 13
+		"",                                  // 14
+		"## Input",                          // 15
+		"",                                  // 16
+		"| name | Description |",            // 17
+		"| --- | --- |",                     // 18
+		"| `stale` | old |",                 // 19
+		"",                                  // 20
+		"### Usage",                         // 21
+		"",                                  // 22
+		"## Usage",                          // 23
+	}, "\n")
+
+	errs := NewDuplicateSectionValidator(data).Validate()
+	want := []string{
+		"'Inputs' on line 3\n  'Input' on line 15",
+		"'Usage' on line 9\n  'Usage' on line 23",
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("expected %d errors, got %d: %v", len(want), len(errs), errs)
+	}
+	for i, w := range want {
+		if !strings.Contains(errs[i].Error(), w) {
+			t.Errorf("error %q does not contain %q", errs[i], w)
+		}
+	}
+
+	// Only the first occurrence is validated and extracted
+	rootNode := parseMarkdown(data)
+	section := Section{Header: "Inputs", RequiredCols: []string{"Name", "Description", "Required"}}
+	if errs := section.validate(rootNode); len(errs) != 0 {
+		t.Errorf("expected the duplicate table to be ignored, got %v", errs)
+	}
+	items, err := extractMarkdownSectionItems(rootNode, "Inputs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(items, ",") != "name" {
+		t.Errorf("items = %v, want [name]", items)
+	}
+}
+
+func TestDuplicateSectionValidatorIgnoresCodeAndComments(t *testing.T) {
+	tests := map[string][]string{
+		"indented code block": {
+			"## Inputs",
+			"",
+			"Example of a README section:",
+			"",
+			"    ## Inputs",
+			"\t## Inputs",
+		},
+		"html comment": {
+			"## Inputs",
+			"",
+			"<!--",
+			"## Inputs",
+			"-->",
+			"",
+			"<!-- ## Inputs -->",
+		},
+	}
+
+	for name, lines := range tests {
+		t.Run(name, func(t *testing.T) {
+			if errs := NewDuplicateSectionValidator(strings.Join(lines, "\n")).Validate(); len(errs) != 0 {
+				t.Errorf("unexpected errors: %v", errs)
+			}
+		})
+	}
+
+	// A heading after the comment is still found
+	data := "## Inputs\n\n<!-- note -->\n## Inputs\n"
+	if errs := NewDuplicateSectionValidator(data).Validate(); len(errs) != 1 || !strings.Contains(errs[0].Error(), "on line 4") {
+		t.Errorf("expected the duplicate on line 4, got %v", errs)
+	}
+}
+
+func TestSetextHeadings(t *testing.T) {
+	table := "| Name | Description |\n| --- | --- |\n| `id` | resource id |\n"
+
+	docs := map[string]string{
+		"atx":    "# Module\n\n## Outputs\n\n" + table + "\n## Notes\n\nNone.\n",
+		"setext": "Module\n======\n\nOutputs\n-------\n\n" + table + "\nNotes\n-----\n\nNone.\n",
+		"mixed":  "# Module\n\nOutputs\n-------\n\n" + table + "\n## Notes\n\nNone.\n",
+	}
+
+	for name, data := range docs {
+		t.Run(name, func(t *testing.T) {
+			rootNode := parseMarkdown(data)
+
+			for _, section := range []Section{
+				{Header: "Outputs", RequiredCols: []string{"Name", "Description"}},
+				{Header: "Notes"},
+			} {
+				if errs := section.validate(rootNode); len(errs) != 0 {
+					t.Errorf("section %s: unexpected errors %v", section.Header, errs)
+				}
+			}
+
+			items, err := extractMarkdownSectionItems(rootNode, "Outputs")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(items, ",") != "id" {
+				t.Errorf("items = %v, want [id]", items)
+			}
+
+			headings, _ := scanMarkdownLines(data)
+			var got []string
+			for _, h := range headings {
+				got = append(got, fmt.Sprintf("%d:%s", h.level, h.text))
+			}
+			if want := "1:Module,2:Outputs,2:Notes"; strings.Join(got, ",") != want {
+				t.Errorf("headings = %v, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestScanMarkdownLinesSetext(t *testing.T) {
+	data := strings.Join([]string{
+		"Multi line", // 1
+		"heading",    // 2
+		"-------",    // 3
+		"",           // 4
+		"---",        // 5 thematic break
+		"",           // 6
+		"- item",     // 7
+		"---",        // 8 not a heading
+		"",           // 9
+		"1. item",    // 10
+		"---",        // 11 not a heading
+		"",           // 12
+		"2) item",    // 13
+		"===",        // 14 not a heading
+		"",           // 15
+		"1.5 notes",  // 16
+		"---",        // 17 not a list item
+		"",           // 18
+		"## Outputs", // 19
+		"",           // 20
+		"Outputs",    // 21
+		"=",          // 22
+	}, "\n")
+
+	headings, _ := scanMarkdownLines(data)
+	want := []markdownLine{
+		{text: "Multi line heading", line: 1, level: 2},
+		{text: "1.5 notes", line: 16, level: 2},
+		{text: "Outputs", line: 19, level: 2},
+		{text: "Outputs", line: 21, level: 1},
+	}
+	if len(headings) != len(want) {
+		t.Fatalf("headings = %+v, want %+v", headings, want)
+	}
+	for i := range want {
+		if headings[i] != want[i] {
+			t.Errorf("heading %d = %+v, want %+v", i, headings[i], want[i])
+		}
+	}
+
+	dup := NewDuplicateSectionValidator("## Inputs\n\nInputs\n------\n").Validate()
+	if len(dup) != 1 || !strings.Contains(dup[0].Error(), "'Inputs' on line 3") {
+		t.Errorf("expected mixed ATX and setext duplicates to be reported, got %v", dup)
+	}
+}
+
+func TestExampleDocsValidator(t *testing.T) {
+	dir := t.TempDir()
+	examples := map[string]map[string]string{
+		"default": {
+			"main.tf":   "module \"rg\" {\n  source = \"cloudnationhq/rg/azure\"\n}\n",
+			"README.md": "# Default\n\n```hcl\nmodule \"rg\" {\n  source = \"CloudNationHQ/rg/azure/\"\n}\n```\n",
+		},
+		"undocumented": {
+			"main.tf": "module \"rg\" {\n  source = \"../../\"\n}\n",
+		},
+		"no-fence": {
+			"main.tf":   "module \"rg\" {\n  source = \"../../\"\n}\n",
+			"README.md": "# No fence\n\nJust prose.\n",
+		},
+		"mismatch": {
+			"main.tf":   "module \"rg\" {\n  source = \"git::https://github.com/cloudnationhq/terraform-azure-rg.git?ref=v1.0.0\"\n}\n",
+			"README.md": "# Mismatch\n\n```hcl\nmodule \"rg\" {\n  source = \"cloudnationhq/kv/azure\"\n}\n```\n",
+		},
+		"legacy": {
+			"main.tf": "module \"rg\" {\n  source = \"../../\"\n}\n",
+		},
+	}
+	for name, files := range examples {
+		if err := os.MkdirAll(filepath.Join(dir, name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		for file, content := range files {
+			if err := os.WriteFile(filepath.Join(dir, name, file), []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	errs := NewExampleDocsValidator(dir, []string{"leg*"}).Validate()
+	var msgs []string
+	for _, err := range errs {
+		msgs = append(msgs, err.Error())
+	}
+	got := strings.Join(msgs, "\n")
+
+	for _, want := range []string{
+		"undocumented\n  README.md does not exist",
+		"no-fence\n  README.md has no code fence",
+		"mismatch\n  module source 'cloudnationhq/kv/azure' in README.md (line 3) does not match main.tf",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected error containing %q, got:\n%s", want, got)
+		}
+	}
+	for _, unwanted := range []string{"default", "legacy"} {
+		if strings.Contains(got, unwanted+"\n") {
+			t.Errorf("unexpected error for example %q:\n%s", unwanted, got)
+		}
+	}
+	if len(errs) != 3 {
+		t.Errorf("expected 3 errors, got %d:\n%s", len(errs), got)
+	}
+
+	if errs := NewExampleDocsValidator(filepath.Join(dir, "missing"), nil).Validate(); len(errs) != 0 {
+		t.Errorf("expected no errors without an examples directory, got %v", errs)
+	}
+}
+
+func TestStrayTerraformValidator(t *testing.T) {
+	writeFiles := func(t *testing.T, root string, files ...string) {
+		t.Helper()
+		for _, file := range files {
+			path := filepath.Join(root, filepath.FromSlash(file))
+			if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte("resource \"azurerm_resource_group\" \"rg\" {}\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	tests := []struct {
+		name      string
+		files     []string
+		terraform string
+		allowed   []string
+		want      []string
+	}{
+		{
+			name: "default layout",
+			files: []string{
+				"main.tf", "modules/sub/main.tf", "examples/default/main.tf",
+				".terraform/modules/rg/main.tf", "tests/testdata/main.tf", "tests/main.tf", "scratch/nested/main.tf",
+			},
+			want: []string{"scratch/nested/main.tf", "tests/main.tf"},
+		},
+		{
+			name:      "split layout",
+			files:     []string{"src/main.tf", "src/modules/sub/main.tf", "examples/default/main.tf", "main.tf"},
+			terraform: "src",
+			want:      []string{"main.tf"},
+		},
+		{
+			name:    "allowed directories",
+			files:   []string{"main.tf", "tests/fixtures/basic/main.tf"},
+			allowed: []string{"tests/fixtures"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeFiles(t, root, tt.files...)
+
+			mv := &MarkdownValidator{
+				docsRoot:             root,
+				terraformRoot:        filepath.Join(root, tt.terraform),
+				allowedTerraformDirs: tt.allowed,
+			}
+			errs := NewStrayTerraformValidator(mv.terraformLayout()).Validate()
+
+			if len(tt.want) == 0 {
+				for _, err := range errs {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if len(errs) != 1 {
+				t.Fatalf("expected one error, got %v", errs)
+			}
+			var verr *ValidationError
+			if !errors.As(errs[0], &verr) || verr.Check != CheckStrayTerraform || verr.Severity != SeverityWarning {
+				t.Errorf("error = %#v, want a warning from the %s check", errs[0], CheckStrayTerraform)
+			}
+			if want := "\n  " + strings.Join(tt.want, "\n  "); !strings.HasSuffix(errs[0].Error(), want) {
+				t.Errorf("error = %q, want it to list %q", errs[0].Error(), tt.want)
+			}
+		})
+	}
+
+	// The linting workflow passes allowed directories through the environment
+	t.Setenv("TF_ALLOWED_DIRS", " tests/fixtures, ,policies ")
+	if got := strings.Join(envList("TF_ALLOWED_DIRS"), ","); got != "tests/fixtures,policies" {
+		t.Errorf("allowed dirs = %q, want tests/fixtures,policies", got)
+	}
+
+	// Flagging stray files leaves the resources collected for the README unchanged
+	root := t.TempDir()
+	writeFiles(t, root, "main.tf", "tests/main.tf", "modules/sub/main.tf", "examples/default/main.tf")
+	resources, _, err := extractTerraformResources(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resources) != 2 {
+		t.Errorf("expected the root and tests resources, got %v", resources)
+	}
+}
+
+type statusTransport int
+
+func (st statusTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: int(st),
+		Body:       io.NopCloser(strings.NewReader("")),
+		Request:    req,
+	}, nil
+}
+
+func TestMarkdownValidatorSeverity(t *testing.T) {
+	t.Setenv("README_PATH", "")
+	t.Setenv("GITHUB_WORKSPACE", t.TempDir())
+
+	readme := filepath.Join(t.TempDir(), "README.md")
+	if err := os.WriteFile(readme, []byte("# Module\n\nDocs at https://example.com/docs.\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: statusTransport(http.StatusServiceUnavailable)}
+
+	tests := []struct {
+		name         string
+		event        string
+		opts         []Option
+		wantWarnings int
+	}{
+		{name: "push", event: "push"},
+		{name: "schedule", event: "schedule", wantWarnings: 1},
+		{name: "explicit warning", event: "push", opts: []Option{WithURLLivenessSeverity(SeverityWarning)}, wantWarnings: 1},
+		{name: "explicit error on schedule", event: "schedule", opts: []Option{WithURLLivenessSeverity(SeverityError)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("GITHUB_EVENT_NAME", tt.event)
+
+			mv, err := NewMarkdownValidator(readme, append(tt.opts, WithHTTPClient(client))...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			errs, warnings := mv.ValidateWithSeverity()
+
+			if len(warnings) != tt.wantWarnings {
+				t.Errorf("warnings = %v, want %d", warnings, tt.wantWarnings)
+			}
+			for _, w := range warnings {
+				var verr *ValidationError
+				if !errors.As(w, &verr) || verr.Check != CheckURLLiveness || verr.Severity != SeverityWarning {
+					t.Errorf("unexpected warning: %#v", w)
+				}
+			}
+
+			// Structural checks always fail the run
+			var urlErrors int
+			for _, err := range errs {
+				if strings.Contains(err.Error(), "URL returned non-OK status") {
+					urlErrors++
+				}
+			}
+			if urlErrors != 1-tt.wantWarnings {
+				t.Errorf("URL errors = %d, want %d", urlErrors, 1-tt.wantWarnings)
+			}
+			if len(errs) == urlErrors {
+				t.Error("expected structural errors for the incomplete README")
+			}
+			if got := len(mv.Validate()); got != len(errs)+len(warnings) {
+				t.Errorf("Validate returned %d errors, want %d", got, len(errs)+len(warnings))
+			}
+		})
+	}
+
+	if _, err := NewMarkdownValidator(readme, WithURLLivenessSeverity("info")); err == nil {
+		t.Error("expected an error for an unknown severity")
+	}
+}
+
+// inFlightTransport records the highest number of requests served at the same time
+type inFlightTransport struct {
+	current atomic.Int32
+	max     atomic.Int32
+}
+
+func (it *inFlightTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	n := it.current.Add(1)
+	defer it.current.Add(-1)
+	for {
+		m := it.max.Load()
+		if n <= m || it.max.CompareAndSwap(m, n) {
+			break
+		}
+	}
+	time.Sleep(10 * time.Millisecond)
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader("")),
+		Request:    req,
+	}, nil
+}
+
+func TestConcurrentURLChecks(t *testing.T) {
+	t.Setenv("README_PATH", "")
+
+	var lines []string
+	for i := 0; i < 8; i++ {
+		lines = append(lines, fmt.Sprintf("See https://example.com/page-%d.", i))
+	}
+	readme := filepath.Join(t.TempDir(), "README.md")
+	if err := os.WriteFile(readme, []byte(strings.Join(lines, "\n")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, n := range []int{1, 3} {
+		transport := &inFlightTransport{}
+		mv, err := NewMarkdownValidator(readme, WithHTTPClient(&http.Client{Transport: transport}), WithConcurrentURLChecks(n))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, v := range mv.validators {
+			if uv, ok := v.(*URLValidator); ok {
+				uv.Validate()
+			}
+		}
+		if got := transport.max.Load(); got > int32(n) || got == 0 {
+			t.Errorf("n=%d: max concurrent requests = %d", n, got)
+		}
+	}
+
+	if _, err := NewMarkdownValidator(readme, WithConcurrentURLChecks(0)); err == nil {
+		t.Error("expected an error for a non-positive limit")
+	}
+}
+
+// jitterTransport fails every request after a delay that varies per URL, so goroutines finish out of order
+type jitterTransport struct{}
+
+func (jitterTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	time.Sleep(time.Duration(len(req.URL.Path)%4) * time.Millisecond)
+	return &http.Response{
+		StatusCode: http.StatusNotFound,
+		Body:       io.NopCloser(strings.NewReader("")),
+		Request:    req,
+	}, nil
+}
+
+func TestURLValidatorDeterministicOrder(t *testing.T) {
+	data := strings.Join([]string{
+		"See https://example.com/zeta and https://example.com/a.",
+		"Also https://example.com/middle-page, https://example.com/bb and ftp://example.com/files.",
+		"Provider docs https://registry.terraform.io/providers/hashicorp/azurerm/latest are skipped.",
+	}, "\n")
+
+	var first []string
+	for run := 0; run < 5; run++ {
+		uv := NewURLValidator(data, &http.Client{Transport: jitterTransport{}})
+		var got []string
+		for _, err := range uv.Validate() {
+			got = append(got, err.Error())
+		}
+
+		if run == 0 {
+			first = got
+			if len(got) != 5 {
+				t.Fatalf("expected 5 errors, got %d:\n%s", len(got), strings.Join(got, "\n"))
+			}
+			if len(uv.checked) != 4 || len(uv.skipped) != 1 || len(uv.invalid) != 1 {
+				t.Errorf("checked = %d, skipped = %d, invalid = %d, want 4, 1 and 1", len(uv.checked), len(uv.skipped), len(uv.invalid))
+			}
+			continue
+		}
+		if strings.Join(got, "\n") != strings.Join(first, "\n") {
+			t.Fatalf("run %d returned errors in a different order:\n%s\nwant:\n%s", run, strings.Join(got, "\n"), strings.Join(first, "\n"))
+		}
+	}
+
+	for i, url := range []string{
+		"ftp://example.com/files",
+		"https://example.com/a",
+		"https://example.com/bb",
+		"https://example.com/middle-page",
+		"https://example.com/zeta",
+	} {
+		if !strings.Contains(first[i], url+"\n") {
+			t.Errorf("error %d = %q, want it for %s", i, first[i], url)
+		}
+	}
+}
+
+func TestMaskMarkdownCode(t *testing.T) {
+	data := strings.Join([]string{
+		"# Module",                          // 1
+		"",                                  // 2
+		"Docs at https://example.com/docs.", // 3
+		"",                                  // 4
+		"```hcl",                            // 5
+		"module \"kv\" {",                   // 6
+		"  vault_uri = \"https://myvault.vault.azure.net\"", // 7
+		"}",                               // 8
+		"```",                             // 9
+		"https://example.com/after-fence", // 10
+		"",                                // 11
+		"Use `https://placeholder.example.net` or ``a ` https://double.example.net`` here.", // 12
+		"<!-- https://comment.example.net",                                                  // 13
+		"     still hidden https://hidden.example.net -->",                                  // 14
+		"Unmatched ` backtick https://example.com/literal",                                  // 15
+		"~~~",                          // 16
+		"https://unclosed.example.net", // 17
+	}, "\n")
+
+	masked := maskMarkdownCode(data)
+	if len(masked) != len(data) || strings.Count(masked, "\n") != strings.Count(data, "\n") {
+		t.Fatal("expected masking to keep length and line breaks")
+	}
+
+	want := []urlMatch{
+		{url: "https://example.com/docs", line: 3},
+		{url: "https://example.com/after-fence", line: 10},
+		{url: "https://example.com/literal", line: 15},
+	}
+	for _, extract := range []func(string) []urlMatch{extractURLs, extractURLsByLine} {
+		got := extract(masked)
+		if len(got) != len(want) {
+			t.Fatalf("urls = %+v, want %+v", got, want)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("url %d = %+v, want %+v", i, got[i], want[i])
+			}
+		}
+	}
+
+	transport := &countingTransport{}
+	if errs := NewURLValidator(data, &http.Client{Transport: transport}).Validate(); len(errs) != 0 {
+		t.Errorf("unexpected errors: %v", errs)
+	}
+	if got := transport.calls.Load(); got != int32(len(want)) {
+		t.Errorf("round trips = %d, want %d", got, len(want))
+	}
+}
+
+func TestCompareResourceCounts(t *testing.T) {
+	code := []string{"azurerm_subnet.a", "azurerm_subnet.b", "azurerm_subnet.c", "azurerm_virtual_network.this"}
+
+	tests := []struct {
+		name   string
+		readme []string
+		strict bool
+		want   []string
+	}{
+		{
+			name:   "listed once",
+			readme: []string{"azurerm_subnet.this", "azurerm_virtual_network.this"},
+		},
+		{
+			name:   "listed once strict",
+			readme: []string{"azurerm_subnet.this", "azurerm_virtual_network.this"},
+			strict: true,
+			want:   []string{"Resources count differs between Terraform and markdown:\n  code defines azurerm_subnet 3 times, README lists it once"},
+		},
+		{
+			name:   "listed per block strict",
+			readme: []string{"azurerm_subnet.a", "azurerm_subnet.b", "azurerm_subnet.c", "azurerm_virtual_network.this"},
+			strict: true,
+		},
+		{
+			name:   "listed too often strict",
+			readme: []string{"azurerm_subnet.a", "azurerm_subnet.b", "azurerm_subnet.c", "azurerm_virtual_network.a", "azurerm_virtual_network.b"},
+			strict: true,
+			want:   []string{"Resources count differs between Terraform and markdown:\n  code defines azurerm_virtual_network once, README lists it 2 times"},
+		},
+		{
+			name:   "missing on both sides",
+			readme: []string{"azurerm_subnet.a", "azurerm_subnet.a", "azurerm_route_table.this"},
+			want: []string{
+				"Resources missing in markdown:\n  azurerm_virtual_network",
+				"Resources in markdown but missing in Terraform:\n  azurerm_route_table",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := compareTerraformAndMarkdown(resourceTypes(code), resourceTypes(tt.readme), "Resources", tt.strict)
+			if len(errs) != len(tt.want) {
+				t.Fatalf("errors = %v, want %q", errs, tt.want)
+			}
+			for i, err := range errs {
+				if err.Error() != tt.want[i] {
+					t.Errorf("error %d = %q, want %q", i, err.Error(), tt.want[i])
+				}
+			}
+		})
+	}
+}